@@ -0,0 +1,102 @@
+package ellipxobj
+
+import "math/big"
+
+// Allocator decides how the quantity traded by an incoming order at a given price
+// is split between the resting orders at that price.
+//
+// Allocate receives the quantity to allocate and the orders of the price level in
+// order of priority (for iceberg orders, Amount is the visible quantity). It must
+// return one amount per order, in the same order, with each amount not exceeding
+// the order's Amount. The sum of amounts must equal the smaller of amount and the
+// total quantity of the level.
+type Allocator interface {
+	Allocate(amount *Amount, level []*Order) []*Amount
+}
+
+// FIFO allocates quantity to orders in order of priority (time priority since all
+// orders of a level have the same price). This is the default allocation.
+type FIFO struct{}
+
+func (FIFO) Allocate(amount *Amount, level []*Order) []*Amount {
+	res := make([]*Amount, len(level))
+	remain := amount.Dup()
+	for n, o := range level {
+		res[n] = allocateUpTo(remain, o.Amount)
+	}
+	return res
+}
+
+// ProRata allocates quantity to orders in proportion of their size. Each allocation
+// is rounded down to a multiple of Step, and allocations lower than Min are set to
+// zero. The quantity left because of rounding is then allocated in order of
+// priority.
+//
+// Step is converted to the precision of the traded amount, so a Step finer than the
+// market's amount precision does not cause any rounding.
+type ProRata struct {
+	Min  *Amount // Minimum allocation (nil for none)
+	Step *Amount // Allocations are rounded down to a multiple of Step (nil for no rounding)
+}
+
+func (p *ProRata) Allocate(amount *Amount, level []*Order) []*Amount {
+	exp := amount.exp
+	total := NewAmount(0, exp)
+	for _, o := range level {
+		total = total.Add(total, o.Amount)
+	}
+
+	res := make([]*Amount, len(level))
+	remain := amount.Dup()
+
+	if total.Cmp(amount) > 0 {
+		var step, min *Amount
+		if p.Step != nil {
+			step = p.Step.Dup().SetExp(exp)
+			if step.Sign() <= 0 {
+				// zero or finer than the precision of amounts
+				step = nil
+			}
+		}
+		if p.Min != nil {
+			min = p.Min.Dup().SetExp(exp)
+		}
+
+		for n, o := range level {
+			// share = amount * o.Amount / total, rounded down
+			v := new(big.Int).Mul(amount.value, o.Amount.Dup().SetExp(exp).value)
+			v = v.Quo(v, total.value)
+			if step != nil {
+				v = v.Sub(v, new(big.Int).Rem(v, step.value))
+			}
+			share := NewAmountRaw(v, exp)
+			if min != nil && share.Cmp(min) < 0 {
+				share = NewAmount(0, exp)
+			}
+			res[n] = share
+			remain = remain.Sub(remain, share)
+		}
+	} else {
+		for n := range level {
+			res[n] = NewAmount(0, exp)
+		}
+	}
+
+	// allocate what is left in order of priority
+	for n, o := range level {
+		avail := NewAmount(0, exp).Sub(o.Amount, res[n])
+		extra := allocateUpTo(remain, avail)
+		res[n] = res[n].Add(res[n], extra)
+	}
+	return res
+}
+
+// allocateUpTo takes up to max from remain, and returns the quantity taken
+func allocateUpTo(remain, max *Amount) *Amount {
+	res := remain.Dup()
+	if res.Cmp(max.Dup().SetExp(res.exp)) > 0 {
+		res = max.Dup().SetExp(res.exp)
+	}
+	remain.Sub(remain, res)
+	return res
+}
@@ -318,6 +318,12 @@ func (a *Amount) Sub(x, y *Amount) *Amount {
 	return a
 }
 
+// percentOf returns pct percent of a, using the exponent of a
+func percentOf(a, pct *Amount) *Amount {
+	res := NewAmount(0, a.exp).Mul(a, pct)
+	return NewAmount(0, a.exp).Div(res, NewAmount(100, 0))
+}
+
 type amountJson struct {
 	Value string  `json:"v"`
 	Exp   int     `json:"e"`
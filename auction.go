@@ -0,0 +1,235 @@
+package ellipxobj
+
+import "sort"
+
+// auctionLevel holds the quantities that can be executed at a given price
+type auctionLevel struct {
+	price   *Amount
+	volume  *Amount // executable volume, the smaller of bids and asks
+	surplus *Amount // bids minus asks at this price
+}
+
+// StartAuction starts the call period of an auction: orders are accepted and
+// rest in the book but do not match, even if their price crosses. Market orders
+// are cancelled since they have no price to rest at. The auction ends when
+// EndAuction is called.
+func (b *OrderBook) StartAuction() []*Event {
+	if b.Auction {
+		return nil
+	}
+	b.Auction = true
+	b.emit(EventAuctionStart, nil, "")
+	return b.flush()
+}
+
+// EndAuction ends the call period of the auction, executes crossing orders at the
+// uncrossing price (see IndicativePrice) and returns to continuous matching. Orders
+// that were not executed stay in the book. If the book is halted, nothing is
+// executed and crossing orders are uncrossed when trading resumes.
+func (b *OrderBook) EndAuction() []*Event {
+	if !b.Auction {
+		return nil
+	}
+	b.Auction = false
+
+	ev := b.emit(EventAuctionEnd, nil, "")
+	if !b.Halted {
+		price, _ := b.IndicativePrice()
+		ev.Price = price.Dup()
+		b.auction()
+	}
+	b.settle()
+
+	return b.flush()
+}
+
+// IndicativePrice returns the price at which the auction would execute if it ended
+// now, and the volume that would be executed. Returns nil if the book is not
+// crossed.
+func (b *OrderBook) IndicativePrice() (*Amount, *Amount) {
+	return b.auctionPrice(b.auctionRef())
+}
+
+// auctionRef returns the reference price used for auctions: the last trade price,
+// or the price bands reference if no trade happened yet
+func (b *OrderBook) auctionRef() *Amount {
+	ref := b.LastPrice
+	if ref == nil && b.Market.Bands != nil {
+		ref = b.Market.Bands.Reference
+	}
+	if ref != nil && ref.exp != b.Market.PriceExp {
+		ref = ref.Dup().SetExp(b.Market.PriceExp)
+	}
+	return ref
+}
+
+// auctionPrice returns the price at which crossing orders of the book can be
+// executed at a single price, and the volume executed at that price. Among the
+// prices of orders in the book, the price is chosen by applying in turn:
+//
+//  1. the maximum executable volume
+//  2. the minimum surplus (absolute difference between bids and asks)
+//  3. market pressure: the highest price if there is a surplus of bids at all
+//     remaining prices, the lowest if there is a surplus of asks at all of them
+//  4. the closest price to ref (if not nil), then the lowest price
+//
+// Returns nil if the book is not crossed.
+func (b *OrderBook) auctionPrice(ref *Amount) (*Amount, *Amount) {
+	if len(b.Bids) == 0 || len(b.Asks) == 0 || b.Bids[0].Price.Cmp(b.Asks[0].Price) < 0 {
+		return nil, nil
+	}
+
+	var levels []*auctionLevel
+	for _, side := range [][]*Order{b.Bids, b.Asks} {
+		for _, o := range side {
+			levels = append(levels, b.auctionLevel(o.Price))
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].price.Cmp(levels[j].price) < 0 })
+
+	// 1. maximum volume
+	levels = bestLevels(levels, func(l *auctionLevel) *Amount { return l.volume.Neg() })
+	if levels[0].volume.Sign() <= 0 {
+		return nil, nil
+	}
+	// 2. minimum surplus
+	levels = bestLevels(levels, func(l *auctionLevel) *Amount { return absAmount(l.surplus) })
+
+	// 3. market pressure
+	buy, sell := true, true
+	for _, l := range levels {
+		buy = buy && l.surplus.Sign() > 0
+		sell = sell && l.surplus.Sign() < 0
+	}
+	switch {
+	case buy:
+		levels = levels[len(levels)-1:]
+	case sell:
+		levels = levels[:1]
+	case ref != nil:
+		// 4. closest to reference price
+		levels = bestLevels(levels, func(l *auctionLevel) *Amount {
+			return absAmount(NewAmount(0, l.price.exp).Sub(l.price, ref))
+		})
+	}
+
+	return levels[0].price, levels[0].volume
+}
+
+// auctionLevel returns the quantities that can be bought and sold at price p
+func (b *OrderBook) auctionLevel(p *Amount) *auctionLevel {
+	buy := NewAmount(0, b.Market.AmountExp)
+	for _, o := range b.Bids {
+		if o.Price.Cmp(p) < 0 {
+			break
+		}
+		buy = buy.Add(buy, o.Amount)
+	}
+	sell := NewAmount(0, b.Market.AmountExp)
+	for _, o := range b.Asks {
+		if o.Price.Cmp(p) > 0 {
+			break
+		}
+		sell = sell.Add(sell, o.Amount)
+	}
+
+	res := &auctionLevel{price: p, volume: buy, surplus: NewAmount(0, buy.exp).Sub(buy, sell)}
+	if sell.Cmp(buy) < 0 {
+		res.volume = sell
+	}
+	return res
+}
+
+// bestLevels returns the levels having the lowest value for key, keeping their order
+func bestLevels(levels []*auctionLevel, key func(*auctionLevel) *Amount) []*auctionLevel {
+	var res []*auctionLevel
+	var best *Amount
+	for _, l := range levels {
+		k := key(l)
+		switch {
+		case best == nil || k.Cmp(best) < 0:
+			best = k
+			res = []*auctionLevel{l}
+		case k.Cmp(best) == 0:
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+func absAmount(a *Amount) *Amount {
+	if a.Sign() < 0 {
+		return a.Neg()
+	}
+	return a
+}
+
+// auction executes all crossing orders of the book at the auction price, in order
+// of priority on each side. The most recent order of each pair is the taker, and
+// self-trade prevention applies as in continuous matching. If the auction price is
+// outside the price bands, trading is halted instead. Orders still crossing after
+// the auction (because of self-trade prevention) are then matched continuously.
+func (b *OrderBook) auction() {
+	if !b.matching() {
+		return
+	}
+	price, _ := b.auctionPrice(b.auctionRef())
+	if price == nil {
+		return
+	}
+	price = price.Dup()
+	if err := b.checkBands(price); err != nil {
+		b.halt(price, "bands")
+		return
+	}
+
+	for len(b.Bids) > 0 && len(b.Asks) > 0 {
+		bid, ask := b.Bids[0], b.Asks[0]
+		if bid.Price.Cmp(price) < 0 || ask.Price.Cmp(price) > 0 {
+			break
+		}
+
+		bidView, askView := *bid, *ask
+		bidView.Price, askView.Price = price, price
+		amt := bid.TradeAmount(&askView)
+		if amt2 := ask.TradeAmount(&bidView); amt2.Cmp(amt) < 0 {
+			amt = amt2
+		}
+		if amt.Sign() <= 0 {
+			// nothing can be traded between these two orders (rounding)
+			break
+		}
+
+		o, m := bid, ask
+		if ask.priority().Cmp(*bid.priority()) > 0 {
+			o, m = ask, bid
+		}
+		t := &Trade{
+			Pair:   b.Market.Pair,
+			Bid:    bid.Meta(),
+			Ask:    ask.Meta(),
+			Type:   o.Type,
+			Amount: amt.Dup(),
+			Price:  price,
+		}
+
+		// the taker is matched as an incoming order, then put back in the book
+		// with its priority if anything remains
+		b.remove(o)
+		o.Status = OrderRunning
+		b.trade(o, m, t)
+
+		switch {
+		case o.Status != OrderRunning:
+			// cancelled as part of a group or by self-trade prevention
+		case isExhausted(o):
+			b.done(o)
+		default:
+			o.Status = OrderOpen
+			b.insert(o)
+			b.replenish(o)
+		}
+	}
+
+	b.uncross()
+}
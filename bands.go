@@ -0,0 +1,139 @@
+package ellipxobj
+
+import "fmt"
+
+// PriceBands configures the circuit breaker of a Market. Orders priced outside the
+// bands are rejected with a *PriceBandError, and a trade that would happen outside
+// the bands halts trading on the book until OrderBook.Resume is called.
+//
+// Static bands are defined around a fixed Reference price, dynamic bands around the
+// price of the last trade. When both are set, prices must be within both.
+type PriceBands struct {
+	Reference       *Amount `json:"ref,omitempty"`         // Reference price for static bands
+	StaticPercent   *Amount `json:"static_pct,omitempty"`  // Width of static bands, as a percentage of Reference
+	DynamicPercent  *Amount `json:"dynamic_pct,omitempty"` // Width of dynamic bands, as a percentage of the last trade price
+	AuctionOnResume bool    `json:"auction_on_resume,omitempty"`
+}
+
+// PriceBandError is returned when an order's price is outside the price bands
+type PriceBandError struct {
+	Price *Amount
+	Low   *Amount // lowest accepted price, nil if no lower limit
+	High  *Amount // highest accepted price, nil if no upper limit
+}
+
+func (e *PriceBandError) Error() string {
+	return fmt.Sprintf("price %s is outside of price bands [%v, %v]", e.Price, e.Low, e.High)
+}
+
+func (e *PriceBandError) Unwrap() error {
+	return ErrPriceOutOfBand
+}
+
+// Limits returns the lowest and highest prices allowed given the last trade price,
+// which can be nil if no trade happened yet. Any of the returned values can be nil
+// if there is no limit in that direction.
+func (p *PriceBands) Limits(last *Amount) (low, high *Amount) {
+	if p.Reference != nil && p.StaticPercent != nil {
+		low, high = bandAround(p.Reference, p.StaticPercent)
+	}
+	if last != nil && p.DynamicPercent != nil {
+		dlow, dhigh := bandAround(last, p.DynamicPercent)
+		if low == nil || dlow.Cmp(low.Dup().SetExp(dlow.exp)) > 0 {
+			low = dlow
+		}
+		if high == nil || dhigh.Cmp(high.Dup().SetExp(dhigh.exp)) < 0 {
+			high = dhigh
+		}
+	}
+	return
+}
+
+// Check returns a *PriceBandError if price is outside the bands
+func (p *PriceBands) Check(price, last *Amount) error {
+	low, high := p.Limits(last)
+	if (low != nil && price.Cmp(low.Dup().SetExp(price.exp)) < 0) || (high != nil && price.Cmp(high.Dup().SetExp(price.exp)) > 0) {
+		return &PriceBandError{Price: price, Low: low, High: high}
+	}
+	return nil
+}
+
+func bandAround(ref, pct *Amount) (*Amount, *Amount) {
+	offset := percentOf(ref, pct)
+	return NewAmount(0, ref.exp).Sub(ref, offset), NewAmount(0, ref.exp).Add(ref, offset)
+}
+
+// checkBands returns an error if price is outside the market's price bands
+func (b *OrderBook) checkBands(price *Amount) error {
+	if b.Market.Bands == nil || price == nil {
+		return nil
+	}
+	return b.Market.Bands.Check(price, b.LastPrice)
+}
+
+// Halt stops all trading on the book. Orders are still accepted but do not match:
+// limit orders rest in the book even if their price crosses, and market orders are
+// cancelled.
+func (b *OrderBook) Halt(reason string) []*Event {
+	b.halt(nil, reason)
+	return b.flush()
+}
+
+func (b *OrderBook) halt(price *Amount, reason string) {
+	if b.Halted {
+		return
+	}
+	b.Halted = true
+	ev := b.emit(EventHalt, nil, reason)
+	ev.Price = price.Dup()
+}
+
+// Resume restarts trading after a halt. Since orders accepted during the halt may
+// cross, the book is first uncrossed: with an auction at a single price if the
+// market's bands have AuctionOnResume set, otherwise by matching crossing orders in
+// the order they were received. If the book is in the call period of an auction,
+// crossing orders are executed when the auction ends.
+func (b *OrderBook) Resume() []*Event {
+	if !b.Halted {
+		return nil
+	}
+	b.Halted = false
+
+	if b.Auction {
+		// crossing orders are executed when the auction ends
+		b.emit(EventResume, nil, "")
+	} else if b.Market.Bands != nil && b.Market.Bands.AuctionOnResume {
+		ev := b.emit(EventResume, nil, "auction")
+		ev.Price, _ = b.IndicativePrice()
+		ev.Price = ev.Price.Dup()
+		b.auction()
+	} else {
+		b.emit(EventResume, nil, "")
+		b.uncross()
+	}
+	b.settle()
+
+	return b.flush()
+}
+
+// uncross matches crossing orders continuously, the most recent order of the two
+// best orders being the taker
+func (b *OrderBook) uncross() {
+	for b.matching() && len(b.Bids) > 0 && len(b.Asks) > 0 {
+		bid, ask := b.Bids[0], b.Asks[0]
+		if bid.Price.Cmp(ask.Price) < 0 {
+			return
+		}
+		o := bid
+		if ask.priority().Cmp(*bid.priority()) > 0 {
+			o = ask
+		}
+		b.remove(o)
+		o.Status = OrderRunning
+		b.match(o)
+		if o.Status == OrderOpen {
+			// o rested again as the best order of its side, so the book isn't crossed anymore
+			return
+		}
+	}
+}
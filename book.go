@@ -0,0 +1,637 @@
+package ellipxobj
+
+import (
+	"sort"
+	"time"
+)
+
+// OrderBook holds the resting orders of a single Market and matches incoming
+// orders against them using price-time priority.
+//
+// Every operation on the book returns the list of events it caused, in the order
+// they happened. An OrderBook is not safe for concurrent use.
+type OrderBook struct {
+	Market *Market
+	Bids   []*Order // Resting buy orders (sorted by price, highest first)
+	Asks   []*Order // Resting sell orders (sorted by price, lowest first)
+	Stops  []*Order // Stop orders waiting for their trigger (sorted by Unique id)
+
+	LastPrice *Amount // Price of the last trade, used to trigger stop orders
+	Halted    bool    // If true, orders are accepted but do not match (see Halt and Resume)
+	Auction   bool    // If true, the book is in the call period of an auction (see StartAuction)
+
+	Allocator Allocator // How quantity is split between orders at the same price (FIFO if nil)
+	Clock     Clock     // Source of time for the ids generated by the book (system time if nil)
+
+	ids       TimeIdUnique
+	events    []*Event
+	groups    map[string]*OrderGroup
+	queue     []*Order // orders waiting to be placed, such as activated bracket children
+	triggered []*Order // stop orders triggered and waiting to be executed
+}
+
+// NewOrderBook returns a new empty OrderBook for the given market
+func NewOrderBook(m *Market) *OrderBook {
+	return &OrderBook{Market: m}
+}
+
+// Execute processes an incoming order: it is matched against resting orders of
+// the opposite side, and any remaining quantity is placed in the book unless the
+// order is a market order or has FlagImmediateOrCancel set.
+//
+// If the order has no Unique id, one is allocated. Self-trade prevention is applied
+// using the order's SelfTrade mode, or the Market's if the order has none.
+//
+// Orders with FlagStop are kept aside until a trade price reaches their StopPrice,
+// at which point FlagStop is removed and they are processed as market orders (or
+// limit orders if Price is set). Trailing stop orders have their StopPrice updated
+// on each trade (see Order.Trail), and lose their trailing parameters once
+// triggered.
+func (b *OrderBook) Execute(o *Order) ([]*Event, error) {
+	if err := b.check(o); err != nil {
+		return nil, err
+	}
+
+	b.place(o)
+	b.settle()
+
+	return b.flush(), nil
+}
+
+// Cancel cancels the order with the given Unique id, which can be either a resting
+// order or a stop order waiting for its trigger.
+func (b *OrderBook) Cancel(id TimeId) ([]*Event, error) {
+	o := b.find(id)
+	if o == nil {
+		return nil, ErrOrderNotFound
+	}
+
+	b.remove(o)
+	b.cancel(o, "user")
+	b.settle()
+
+	return b.flush(), nil
+}
+
+// Expire cancels all resting orders which expired at time now, in order of
+// expiry then priority. Since the time is provided by the caller, running the
+// same sequence of operations on two books produces the same events.
+func (b *OrderBook) Expire(now TimeId) []*Event {
+	var expired []*Order
+	for _, side := range [][]*Order{b.Bids, b.Asks, b.Stops} {
+		for _, o := range side {
+			if o.IsExpired(now) {
+				expired = append(expired, o)
+			}
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		if c := expired[i].Expires.Cmp(*expired[j].Expires); c != 0 {
+			return c < 0
+		}
+		return expired[i].priority().Cmp(*expired[j].priority()) < 0
+	})
+
+	for _, o := range expired {
+		if o.Status == OrderCancel {
+			// already cancelled as part of a group
+			continue
+		}
+		b.remove(o)
+		b.cancel(o, "expired")
+	}
+	b.settle()
+
+	return b.flush()
+}
+
+// ExpireAt is a convenience wrapper around Expire taking a time.Time
+func (b *OrderBook) ExpireAt(now time.Time) []*Event {
+	return b.Expire(TimeId{Unix: uint64(now.Unix()), Nano: uint32(now.Nanosecond())})
+}
+
+// Amend modifies the resting order targeted by n.Target (see Order.Amend). If the
+// order keeps its priority it is updated in place, otherwise it receives a new
+// Unique id (n.Unique if set), which also resets the priority of iceberg slices, and
+// is processed again as if it was a new incoming order, which means it may match if
+// its new price crosses the book.
+func (b *OrderBook) Amend(n *Order) ([]*Event, error) {
+	if n.Target == nil {
+		return nil, ErrAmendTargetMismatch
+	}
+	o := b.find(*n.Target)
+	if o == nil {
+		return nil, ErrOrderNotFound
+	}
+	if err := b.Market.normalize(n); err != nil {
+		return nil, err
+	}
+	if err := b.checkBands(n.Price); err != nil {
+		return nil, err
+	}
+
+	keep, err := o.Amend(n)
+	if err != nil {
+		return nil, err
+	}
+	if keep || o.Status == OrderStop {
+		// stop orders have no price priority, and stay stop orders
+		b.emit(EventAmend, o, "")
+		b.checkStop(o)
+		b.settle()
+		return b.flush(), nil
+	}
+
+	b.remove(o)
+	var id *TimeId
+	if n.Unique != nil {
+		id = &TimeId{}
+		*id = *n.Unique
+	}
+	b.allocate(o, id)
+	o.Priority = nil
+	o.Status = OrderRunning
+	b.emit(EventAmend, o, "priority_lost")
+	b.match(o)
+	b.settle()
+
+	return b.flush(), nil
+}
+
+// check validates incoming order o and normalizes its amounts
+func (b *OrderBook) check(o *Order) error {
+	if err := o.IsValid(); err != nil {
+		return err
+	}
+	if o.Pair != b.Market.Pair {
+		return ErrPairMismatch
+	}
+	if err := b.Market.normalize(o); err != nil {
+		return err
+	}
+	return b.checkBands(o.Price)
+}
+
+// place processes a new order that was already checked
+func (b *OrderBook) place(o *Order) {
+	b.allocate(o, o.Unique)
+	if o.TimeInForce == TimeInForceDay && o.Expires == nil {
+		o.Expires = b.Market.dayExpiry(*o.Unique)
+	}
+	o.Status = OrderRunning
+	b.emit(EventAccept, o, "")
+
+	switch {
+	case o.IsExpired(*o.Unique):
+		b.cancel(o, "expired")
+	case o.Flags.Has(FlagStop):
+		o.Status = OrderStop
+		o.Trail(b.LastPrice)
+		b.Stops = append(b.Stops, o)
+		b.emit(EventOpen, o, "")
+		b.checkStop(o)
+	default:
+		b.match(o)
+	}
+}
+
+// settle places queued orders and triggers stop orders until nothing is left to do.
+// Since triggered orders can cause trades, which can trigger more stop orders, this
+// needs to loop.
+func (b *OrderBook) settle() {
+	for {
+		if len(b.queue) > 0 {
+			o := b.queue[0]
+			b.queue = b.queue[1:]
+			if o.Status != OrderCancel {
+				// orders can be cancelled by their group while queued
+				b.place(o)
+			}
+			continue
+		}
+		if len(b.triggered) == 0 {
+			return
+		}
+		o := b.triggered[0]
+		b.triggered = b.triggered[1:]
+		if o.Status != OrderStop {
+			// cancelled in the meantime
+			continue
+		}
+		b.remove(o)
+		o.Status = OrderRunning
+		o.Flags &^= FlagStop
+		// trailing parameters only apply to stop orders
+		o.TrailOffset, o.TrailPercent, o.TrailRef = nil, nil, nil
+		b.emit(EventTrigger, o, "")
+		b.groupActivity(o)
+		b.match(o)
+	}
+}
+
+// tradePrice is called for each trade, and updates stop orders accordingly
+func (b *OrderBook) tradePrice(price *Amount) {
+	b.LastPrice = price
+	for _, o := range b.Stops {
+		o.Trail(price)
+		b.checkStop(o)
+	}
+}
+
+// checkStop adds stop order o to the list of triggered orders if the last trade
+// price reached its trigger price
+func (b *OrderBook) checkStop(o *Order) {
+	if !o.IsTriggered(b.LastPrice) {
+		return
+	}
+	for _, v := range b.triggered {
+		if v == o {
+			return
+		}
+	}
+	b.triggered = append(b.triggered, o)
+}
+
+// allocate sets the Unique id of order o to id, or to a newly generated id if nil
+func (b *OrderBook) allocate(o *Order, id *TimeId) {
+	if id == nil {
+		o.Unique = b.newId("order")
+		return
+	}
+	// make sure ids we generate from now on are after this order
+	t := *id
+	b.ids.Unique(&t)
+	o.Unique = id
+}
+
+// find returns the resting or stop order with the given Unique id, or nil
+func (b *OrderBook) find(id TimeId) *Order {
+	for _, side := range [][]*Order{b.Bids, b.Asks, b.Stops} {
+		for _, o := range side {
+			if o.Unique.Cmp(id) == 0 {
+				return o
+			}
+		}
+	}
+	return nil
+}
+
+// match runs order o against the opposite side of the book, then either rests or
+// terminates it depending on what remains
+//
+// Market orders do not match beyond the Market's collar, if any. If the book is
+// halted or in an auction, or a trade would happen outside the price bands, no
+// match happens. Orders with FlagFillOrKill are cancelled without any trade if
+// they can't be fully executed (see Simulate).
+func (b *OrderBook) match(o *Order) {
+	side := b.side(o.Type.Reverse())
+
+	if o.Flags.Has(FlagFillOrKill) && !b.simulate(o).Complete {
+		b.cancel(o, "fok")
+		return
+	}
+
+	var limit *Amount
+	if o.Price == nil && len(*side) > 0 {
+		limit = b.Market.collar(o.Type, (*side)[0].Price)
+	}
+	collared := false
+
+	for b.matching() && len(*side) > 0 && !isExhausted(o) && o.Status == OrderRunning {
+		m := (*side)[0]
+		if limit != nil && !priceWithin(o.Type, m.Price, limit) {
+			collared = true
+			break
+		}
+		if !o.crosses(m) {
+			break
+		}
+		if err := b.checkBands(m.Price); err != nil {
+			b.halt(m.Price, "bands")
+			break
+		}
+
+		// split what o can trade at this price between the orders of the level
+		level, views := b.level(*side)
+		avail := o.TradeAmount(levelOrder(views))
+		if avail.Sign() <= 0 {
+			break
+		}
+		alloc := b.allocator().Allocate(avail, views)
+
+		for n, m := range level {
+			if alloc[n].Sign() <= 0 {
+				continue
+			}
+			if !b.fill(o, m, alloc[n]) {
+				return
+			}
+			if isExhausted(o) || o.Status != OrderRunning {
+				break
+			}
+		}
+	}
+
+	switch {
+	case o.Status != OrderRunning:
+		// cancelled as part of a group or by self-trade prevention
+	case isExhausted(o):
+		b.done(o)
+	case collared:
+		b.cancel(o, "collar")
+	case o.Price == nil && b.Halted:
+		b.cancel(o, "halted")
+	case o.Price == nil && b.Auction:
+		b.cancel(o, "auction")
+	case o.Price == nil:
+		b.cancel(o, "market")
+	case o.Flags.Has(FlagFillOrKill):
+		b.cancel(o, "fok")
+	case o.Flags.Has(FlagImmediateOrCancel):
+		b.cancel(o, "ioc")
+	default:
+		b.rest(o)
+	}
+}
+
+// fill executes a trade of up to amount between incoming order o and resting order
+// m. Returns false if o was cancelled by self-trade prevention.
+func (b *OrderBook) fill(o, m *Order, amount *Amount) bool {
+	if m.Status != OrderOpen {
+		// removed by a previous fill
+		return true
+	}
+	t := o.Matches(m.view())
+	if t == nil {
+		return true
+	}
+	if t.Amount.Cmp(amount) > 0 {
+		t.Amount = amount.Dup()
+	}
+	return b.trade(o, m, t)
+}
+
+// trade executes trade t between order o and resting order m, unless self-trade
+// prevention applies. Returns false if o was cancelled by self-trade prevention.
+func (b *OrderBook) trade(o, m *Order, t *Trade) bool {
+	if mode := b.selfTradeMode(o, m); mode != SelfTradeNone {
+		return b.preventSelfTrade(o, m, t, mode)
+	}
+
+	t.Id = b.newId("trade")
+	o.Deduct(t)
+	m.Deduct(t)
+	b.emitTrade(t)
+	b.tradePrice(t.Price)
+	b.groupTrade(o, t)
+	b.groupTrade(m, t)
+
+	switch {
+	case m.Status != OrderOpen:
+		// cancelled as part of a group
+	case isExhausted(m):
+		b.remove(m)
+		b.done(m)
+	default:
+		b.replenish(m)
+	}
+	return true
+}
+
+// level returns the orders at the best price of side, and their views as they can
+// be matched
+func (b *OrderBook) level(side []*Order) (orders, views []*Order) {
+	for _, m := range side {
+		if m.Price.Cmp(side[0].Price) != 0 {
+			break
+		}
+		orders = append(orders, m)
+		views = append(views, m.view())
+	}
+	return
+}
+
+// levelOrder returns an order representing the total quantity of a price level
+func levelOrder(level []*Order) *Order {
+	res := &Order{Price: level[0].Price, Amount: NewAmount(0, level[0].Amount.exp)}
+	for _, m := range level {
+		res.Amount = res.Amount.Add(res.Amount, m.Amount)
+	}
+	return res
+}
+
+func (b *OrderBook) allocator() Allocator {
+	if b.Allocator == nil {
+		return FIFO{}
+	}
+	return b.Allocator
+}
+
+// selfTradeMode returns the self-trade prevention mode that applies between
+// incoming order o and resting order m, or SelfTradeNone if they can trade
+func (b *OrderBook) selfTradeMode(o, m *Order) SelfTradeMode {
+	if !o.IsSelfTrade(m) {
+		return SelfTradeNone
+	}
+	if o.SelfTrade != SelfTradeNone {
+		return o.SelfTrade
+	}
+	return b.Market.SelfTrade
+}
+
+// preventSelfTrade applies the given self-trade prevention mode between incoming
+// order o and resting order m, t being the trade that would have happened.
+// Returns true if o is still active and matching should continue.
+func (b *OrderBook) preventSelfTrade(o, m *Order, t *Trade, mode SelfTradeMode) bool {
+	ev := b.emit(EventSelfTrade, o, mode.String())
+	ev.Other = m.Meta()
+
+	switch mode {
+	case SelfTradeCancelNewest:
+		b.cancel(o, "self_trade")
+		return false
+	case SelfTradeCancelOldest:
+		b.remove(m)
+		b.cancel(m, "self_trade")
+		return true
+	case SelfTradeCancelBoth:
+		b.remove(m)
+		b.cancel(m, "self_trade")
+		b.cancel(o, "self_trade")
+		return false
+	case SelfTradeDecrement:
+		// reduce both orders by the quantity that would have been traded, without
+		// actually trading
+		o.Deduct(t)
+		m.Deduct(t)
+		if isExhausted(m) {
+			b.remove(m)
+			b.cancel(m, "self_trade")
+		} else {
+			b.replenish(m)
+		}
+		if isExhausted(o) {
+			b.cancel(o, "self_trade")
+			return false
+		}
+		return true
+	default:
+		return true
+	}
+}
+
+// rest places order o in the book as an open order
+func (b *OrderBook) rest(o *Order) {
+	if o.Amount == nil {
+		// resting orders always need an amount to be matched against
+		o.Amount = o.NominalAmount(b.Market.AmountExp)
+	}
+	o.Status = OrderOpen
+	o.showSlice()
+	b.insert(o)
+	b.emit(EventOpen, o, "")
+}
+
+// replenish shows a new slice of iceberg order m if its visible slice was fully
+// consumed. The new slice loses its time priority and gets a new Priority, while
+// the order keeps its Unique id.
+func (b *OrderBook) replenish(m *Order) {
+	if m.Visible == nil || m.Visible.Sign() > 0 {
+		return
+	}
+	b.remove(m)
+	m.Priority = b.newId("order")
+	m.showSlice()
+	b.insert(m)
+	b.emit(EventReplenish, m, "")
+}
+
+// insert adds order o to its side of the book according to its priority
+func (b *OrderBook) insert(o *Order) {
+	side := b.side(o.Type)
+	i := sort.Search(len(*side), func(i int) bool { return hasPriority(o, (*side)[i]) })
+	*side = append(*side, nil)
+	copy((*side)[i+1:], (*side)[i:])
+	(*side)[i] = o
+}
+
+// remove removes order o from the book or from the stop orders if present
+func (b *OrderBook) remove(o *Order) {
+	side := b.side(o.Type)
+	if o.Status == OrderStop {
+		side = &b.Stops
+	}
+	for i, v := range *side {
+		if v == o {
+			*side = append((*side)[:i], (*side)[i+1:]...)
+			return
+		}
+	}
+}
+
+// cancel marks order o as cancelled. The order must have been removed from the
+// book already if it was resting.
+func (b *OrderBook) cancel(o *Order, reason string) {
+	o.Status = OrderCancel
+	b.emit(EventCancel, o, reason)
+	b.groupClosed(o)
+}
+
+// done marks order o as fully executed. The order must have been removed from the
+// book already if it was resting.
+func (b *OrderBook) done(o *Order) {
+	o.Status = OrderDone
+	b.emit(EventDone, o, "")
+	b.groupClosed(o)
+}
+
+func (b *OrderBook) side(t OrderType) *[]*Order {
+	if t == TypeBid {
+		return &b.Bids
+	}
+	return &b.Asks
+}
+
+func (b *OrderBook) newId(typ string) *TimeId {
+	t := NewTimeIdWithClock(b.Clock)
+	b.ids.Unique(t)
+	t.Type = typ
+	return t
+}
+
+func (b *OrderBook) emit(typ EventType, o *Order, reason string) *Event {
+	ev := &Event{
+		Id:     b.newId("event"),
+		Type:   typ,
+		Order:  o.Dup(),
+		Reason: reason,
+	}
+	b.events = append(b.events, ev)
+	return ev
+}
+
+func (b *OrderBook) emitTrade(t *Trade) {
+	b.events = append(b.events, &Event{Id: b.newId("event"), Type: EventTrade, Trade: t})
+}
+
+// flush returns the events accumulated so far and resets the list
+func (b *OrderBook) flush() []*Event {
+	res := b.events
+	b.events = nil
+	return res
+}
+
+// view returns the order as it can be matched by incoming orders, which for iceberg
+// orders is limited to the visible slice
+func (o *Order) view() *Order {
+	if o.Visible == nil {
+		return o
+	}
+	res := &Order{}
+	*res = *o
+	res.Amount = o.Visible
+	return res
+}
+
+// hasPriority returns true if order a should be matched before order b, both
+// orders being on the same side of the book
+func hasPriority(a, b *Order) bool {
+	if c := a.Price.Cmp(b.Price); c != 0 {
+		if a.Type == TypeBid {
+			return c > 0
+		}
+		return c < 0
+	}
+	return a.priority().Cmp(*b.priority()) < 0
+}
+
+// matching returns true if orders can currently match
+func (b *OrderBook) matching() bool {
+	return !b.Halted && !b.Auction
+}
+
+// crosses returns true if the price of o allows it to trade with resting order m
+func (o *Order) crosses(m *Order) bool {
+	return o.Price == nil || priceWithin(o.Type, m.Price, o.Price)
+}
+
+// priceWithin returns true if an order of type typ with the given limit price can
+// trade at price
+func priceWithin(typ OrderType, price, limit *Amount) bool {
+	if typ == TypeBid {
+		return price.Cmp(limit) <= 0
+	}
+	return price.Cmp(limit) >= 0
+}
+
+// isExhausted returns true if nothing remains to be traded on order o
+func isExhausted(o *Order) bool {
+	if o.Amount != nil && o.Amount.Sign() <= 0 {
+		return true
+	}
+	if o.SpendLimit != nil && o.SpendLimit.Sign() <= 0 {
+		return true
+	}
+	return false
+}
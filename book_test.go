@@ -0,0 +1,774 @@
+package ellipxobj
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testMarket() *Market {
+	return &Market{Pair: Pair("BTC", "USD"), AmountExp: 8, PriceExp: 5}
+}
+
+func testOrder(id, user string, typ OrderType, amount, price string) *Order {
+	o := NewOrder(Pair("BTC", "USD"), typ).SetId(id, "test")
+	o.UserId = user
+	o.Amount = must(NewAmountFromString(amount, 8))
+	if price != "" {
+		o.Price = must(NewAmountFromString(price, 5))
+	}
+	return o
+}
+
+func eventTypes(events []*Event) []EventType {
+	res := make([]EventType, len(events))
+	for n, ev := range events {
+		res[n] = ev.Type
+	}
+	return res
+}
+
+func TestBookMatch(t *testing.T) {
+	b := NewOrderBook(testMarket())
+
+	must(b.Execute(testOrder("a1", "alice", TypeAsk, "1", "101")))
+	must(b.Execute(testOrder("a2", "alice", TypeAsk, "1", "100")))
+
+	if len(b.Asks) != 2 || b.Asks[0].OrderId != "a2" {
+		t.Fatalf("asks not sorted by price")
+	}
+
+	bid := testOrder("b1", "bob", TypeBid, "1.5", "101")
+	events := must(b.Execute(bid))
+
+	var trades []*Trade
+	for _, ev := range events {
+		if ev.Type == EventTrade {
+			trades = append(trades, ev.Trade)
+		}
+	}
+	if len(trades) != 2 {
+		t.Fatalf("expected 2 trades, got %d", len(trades))
+	}
+	if trades[0].Price.String() != "100.00000" || trades[1].Amount.String() != "0.50000000" {
+		t.Errorf("unexpected trades %s / %s", trades[0], trades[1])
+	}
+	if bid.Status != OrderDone {
+		t.Errorf("expected bid to be done, got %s", bid.Status)
+	}
+	if len(b.Asks) != 1 || b.Asks[0].Amount.String() != "0.50000000" {
+		t.Errorf("unexpected remaining asks")
+	}
+}
+
+func TestBookPrecision(t *testing.T) {
+	b := NewOrderBook(testMarket())
+
+	o := testOrder("a1", "alice", TypeAsk, "1", "")
+	o.Amount = must(NewAmountFromString("1", 3))
+	o.Price = must(NewAmountFromString("100.123456", 6))
+	amount, str := o.Amount, o.Amount.String()
+
+	if _, err := b.Execute(o); !errors.Is(err, ErrAmountPrecision) {
+		t.Fatalf("expected ErrAmountPrecision, got %v", err)
+	}
+	if o.Amount != amount || o.Amount.String() != str || o.Price.String() != "100.123456" {
+		t.Errorf("rejected order was modified: amount %s price %s", o.Amount, o.Price)
+	}
+	if len(b.Asks) != 0 {
+		t.Errorf("rejected order rests in the book")
+	}
+}
+
+func TestBookSelfTrade(t *testing.T) {
+	cases := []struct {
+		mode      SelfTradeMode
+		asks      int // remaining asks
+		bidStatus OrderStatus
+		bidAmount string // remaining bid amount
+	}{
+		{SelfTradeNone, 0, OrderOpen, "0.10000000"},
+		{SelfTradeCancelNewest, 2, OrderCancel, "1.00000000"},
+		{SelfTradeCancelOldest, 0, OrderOpen, "0.50000000"},
+		{SelfTradeCancelBoth, 1, OrderCancel, "1.00000000"},
+		{SelfTradeDecrement, 0, OrderOpen, "0.10000000"},
+	}
+
+	for _, c := range cases {
+		b := NewOrderBook(testMarket())
+		must(b.Execute(testOrder("a1", "alice", TypeAsk, "0.4", "100")))
+		must(b.Execute(testOrder("a2", "carol", TypeAsk, "0.5", "100")))
+
+		bid := testOrder("b1", "alice", TypeBid, "1", "100")
+		bid.SelfTrade = c.mode
+		events := must(b.Execute(bid))
+
+		if len(b.Asks) != c.asks {
+			t.Errorf("%s: expected %d remaining asks, got %d", c.mode, c.asks, len(b.Asks))
+		}
+		if bid.Status != c.bidStatus {
+			t.Errorf("%s: expected bid status %s, got %s", c.mode, c.bidStatus, bid.Status)
+		}
+		if bid.Amount.String() != c.bidAmount {
+			t.Errorf("%s: expected bid amount %s, got %s", c.mode, c.bidAmount, bid.Amount)
+		}
+
+		if c.mode == SelfTradeNone {
+			continue
+		}
+		found := false
+		for _, ev := range events {
+			if ev.Type == EventSelfTrade {
+				found = true
+				if ev.Other.OrderId != "a1" || ev.Reason != c.mode.String() {
+					t.Errorf("%s: unexpected self trade event %+v", c.mode, ev)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s: no self trade event in %v", c.mode, eventTypes(events))
+		}
+	}
+}
+
+func TestBookSelfTradeMarketDefault(t *testing.T) {
+	m := testMarket()
+	m.SelfTrade = SelfTradeCancelNewest
+	b := NewOrderBook(m)
+
+	must(b.Execute(testOrder("a1", "alice", TypeAsk, "1", "100")))
+	bid := testOrder("b1", "alice", TypeBid, "1", "100")
+	must(b.Execute(bid))
+
+	if bid.Status != OrderCancel || len(b.Asks) != 1 {
+		t.Errorf("market self-trade mode was not applied")
+	}
+}
+
+func TestBookAmend(t *testing.T) {
+	b := NewOrderBook(testMarket())
+
+	a1 := testOrder("a1", "alice", TypeAsk, "1", "100")
+	a2 := testOrder("a2", "carol", TypeAsk, "1", "100")
+	must(b.Execute(a1))
+	must(b.Execute(a2))
+
+	// decreasing the amount keeps priority
+	n := testOrder("a1", "alice", TypeAsk, "0.5", "")
+	n.Target = a1.Unique
+	must(b.Amend(n))
+	if b.Asks[0] != a1 || a1.Amount.String() != "0.50000000" || a1.Version != 1 {
+		t.Errorf("expected a1 to keep priority with amount 0.5, got %s", a1)
+	}
+
+	// increasing the amount loses priority
+	n = testOrder("a1", "alice", TypeAsk, "2", "")
+	n.Target = a1.Unique
+	must(b.Amend(n))
+	if b.Asks[0] != a2 || b.Asks[1] != a1 || a1.Version != 2 {
+		t.Errorf("expected a1 to lose priority")
+	}
+
+	// changing the price makes the order match
+	must(b.Execute(testOrder("b1", "bob", TypeBid, "1", "99")))
+	n = testOrder("a2", "carol", TypeAsk, "1", "99")
+	n.Target = a2.Unique
+	events := must(b.Amend(n))
+	if a2.Status != OrderDone || len(b.Bids) != 0 {
+		t.Errorf("expected amended order to match, got events %v", eventTypes(events))
+	}
+
+	// mismatching amendments are refused
+	n = testOrder("a1", "bob", TypeAsk, "1", "")
+	n.Target = a1.Unique
+	if _, err := b.Amend(n); err != ErrAmendNotCompatible {
+		t.Errorf("expected ErrAmendNotCompatible, got %v", err)
+	}
+}
+
+func TestBookExpire(t *testing.T) {
+	m := testMarket()
+	m.DayEnd = 22 * 3600 // 22:00 UTC
+	b := NewOrderBook(m)
+
+	gtd := testOrder("a1", "alice", TypeAsk, "1", "100")
+	gtd.Unique = &TimeId{Type: "order", Unix: 1715773941}
+	gtd.TimeInForce = TimeInForceGTD
+	gtd.Expires = &TimeId{Unix: 1715774000}
+	must(b.Execute(gtd))
+
+	day := testOrder("a2", "alice", TypeAsk, "1", "101")
+	day.Unique = &TimeId{Type: "order", Unix: 1715773942}
+	day.TimeInForce = TimeInForceDay
+	must(b.Execute(day))
+	if day.Expires == nil || day.Expires.Unix != 1715810400 {
+		t.Fatalf("unexpected day order expiry %v", day.Expires)
+	}
+
+	must(b.Execute(testOrder("a3", "alice", TypeAsk, "1", "102")))
+
+	events := b.Expire(TimeId{Unix: 1715773999})
+	if len(events) != 0 {
+		t.Errorf("expected no event before expiry, got %v", eventTypes(events))
+	}
+
+	events = b.Expire(TimeId{Unix: 1715810400})
+	if len(events) != 2 || events[0].Order.OrderId != "a1" || events[1].Order.OrderId != "a2" || events[0].Reason != "expired" {
+		t.Errorf("unexpected expiry events %v", events)
+	}
+	if len(b.Asks) != 1 || gtd.Status != OrderCancel {
+		t.Errorf("expired orders not removed from book")
+	}
+
+	late := testOrder("a4", "alice", TypeAsk, "1", "100")
+	late.TimeInForce = TimeInForceGTD
+	late.Expires = &TimeId{Unix: 1715773941}
+	must(b.Execute(late))
+	if late.Status != OrderCancel {
+		t.Errorf("expected already expired order to be cancelled")
+	}
+}
+
+func TestBookIceberg(t *testing.T) {
+	b := NewOrderBook(testMarket())
+
+	ice := testOrder("a1", "alice", TypeAsk, "3", "100")
+	ice.Display = must(NewAmountFromString("1", 8))
+	ice.SpendLimit = must(NewAmountFromString("300", 5))
+	must(b.Execute(ice))
+	must(b.Execute(testOrder("a2", "carol", TypeAsk, "1", "100")))
+
+	d := b.Depth(0)
+	if len(d.Asks) != 1 || d.Asks[0].Amount.String() != "2.00000000" || d.Asks[0].Count != 2 {
+		t.Errorf("unexpected depth %+v", d.Asks[0])
+	}
+
+	first := *ice.Unique
+	events := must(b.Execute(testOrder("b1", "bob", TypeBid, "1.5", "100")))
+
+	if ice.Amount.String() != "2.00000000" || ice.Visible.String() != "1.00000000" {
+		t.Errorf("unexpected iceberg state amount=%s visible=%s", ice.Amount, ice.Visible)
+	}
+	if ice.Unique.Cmp(first) != 0 || ice.Priority == nil || ice.Priority.Cmp(first) <= 0 || b.Asks[1] != ice {
+		t.Errorf("replenished slice should lose priority and keep its id, got events %v", eventTypes(events))
+	}
+	if b.Asks[0].Amount.String() != "0.50000000" {
+		t.Errorf("expected a2 to be partially filled, got %s", b.Asks[0].Amount)
+	}
+
+	c := &Checkpoint{Pair: b.Market.Pair, Asks: b.Asks}
+	pub := c.Public()
+	if pub.Asks[1].Amount.String() != "1.00000000" || pub.Asks[1].Display != nil || pub.Asks[1].SpendLimit != nil || pub.Asks[1].Priority != nil {
+		t.Errorf("public checkpoint discloses hidden quantity: %s", pub.Asks[1])
+	}
+	if c.Asks[1].Amount.String() != "2.00000000" {
+		t.Errorf("checkpoint must keep full amount, got %s", c.Asks[1].Amount)
+	}
+
+	// the original id still designates the order
+	if events := must(b.Cancel(first)); len(events) != 1 || events[0].Type != EventCancel || ice.Status != OrderCancel {
+		t.Errorf("failed to cancel replenished iceberg, got %v", eventTypes(events))
+	}
+}
+
+func testStop(o *Order, stop string) *Order {
+	o.Flags |= FlagStop
+	o.StopPrice = must(NewAmountFromString(stop, 5))
+	return o
+}
+
+func TestBookOCO(t *testing.T) {
+	b := NewOrderBook(testMarket())
+
+	tp := testOrder("a1", "alice", TypeAsk, "1", "110")
+	sl := testStop(testOrder("a2", "alice", TypeAsk, "1", ""), "90")
+	g := &OrderGroup{Id: "g1", Type: GroupOCO, Orders: []*Order{tp, sl}}
+	must(b.ExecuteGroup(g))
+
+	if len(b.Asks) != 1 || len(b.Stops) != 1 || sl.Status != OrderStop {
+		t.Fatalf("unexpected book state after OCO placement")
+	}
+
+	must(b.Execute(testOrder("b1", "bob", TypeBid, "0.5", "110")))
+	if tp.Status != OrderOpen || sl.Status != OrderCancel || len(b.Stops) != 0 {
+		t.Errorf("expected stop order to be cancelled after partial fill, got tp=%s sl=%s", tp.Status, sl.Status)
+	}
+
+	// an order of the group trades on entry, the others must be cancelled only once
+	must(b.Execute(testOrder("b2", "bob", TypeBid, "1", "105")))
+	tp = testOrder("a3", "alice", TypeAsk, "1", "105")
+	sl = testStop(testOrder("a4", "alice", TypeAsk, "1", ""), "90")
+	events := must(b.ExecuteGroup(&OrderGroup{Id: "g3", Type: GroupOCO, Orders: []*Order{tp, sl}}))
+	expect := []struct {
+		typ   EventType
+		order string
+	}{
+		{EventAccept, "a3"},
+		{EventTrade, ""},
+		{EventCancel, "a4"},
+		{EventDone, "b2"},
+		{EventDone, "a3"},
+	}
+	if len(events) != len(expect) {
+		t.Fatalf("unexpected OCO events %v", eventTypes(events))
+	}
+	for n, e := range expect {
+		if events[n].Type != e.typ || (e.order != "" && events[n].Order.OrderId != e.order) {
+			t.Errorf("unexpected OCO event %d: %s", n, events[n])
+		}
+	}
+	if events[2].Reason != "oco" || sl.Status != OrderCancel || len(b.Stops) != 0 {
+		t.Errorf("expected stop order to be cancelled by OCO, got %s", sl.Status)
+	}
+
+	if _, err := b.ExecuteGroup(&OrderGroup{Id: "g2", Type: GroupOCO, Orders: []*Order{testOrder("x", "bob", TypeAsk, "1", "120")}}); err != ErrGroupNotValid {
+		t.Errorf("expected ErrGroupNotValid, got %v", err)
+	}
+}
+
+func TestBookBracket(t *testing.T) {
+	b := NewOrderBook(testMarket())
+	must(b.Execute(testOrder("a1", "carol", TypeAsk, "0.6", "100")))
+
+	entry := testOrder("b1", "alice", TypeBid, "1", "100")
+	tp := testOrder("b2", "alice", TypeAsk, "1", "110")
+	sl := testStop(testOrder("b3", "alice", TypeAsk, "1", ""), "90")
+	g := &OrderGroup{Id: "g1", Type: GroupBracket, Orders: []*Order{entry, tp, sl}}
+	must(b.ExecuteGroup(g))
+
+	if entry.Status != OrderOpen || tp.Status != OrderPending || sl.Status != OrderPending {
+		t.Fatalf("children must wait for the entry, got entry=%s tp=%s sl=%s", entry.Status, tp.Status, sl.Status)
+	}
+
+	// cancelling the partially filled entry activates children for the filled quantity
+	must(b.Cancel(*entry.Unique))
+	if tp.Status != OrderOpen || sl.Status != OrderStop || tp.Amount.String() != "0.60000000" {
+		t.Fatalf("children not activated, got tp=%s sl=%s", tp, sl.Status)
+	}
+
+	// a trade at 90 triggers the stop loss, which cancels the take profit
+	must(b.Execute(testOrder("c1", "carol", TypeBid, "2", "90")))
+	events := must(b.Execute(testOrder("c2", "bob", TypeAsk, "0.1", "90")))
+	if sl.Status != OrderDone || tp.Status != OrderCancel {
+		t.Errorf("expected stop loss to execute and take profit to be cancelled, got %v", eventTypes(events))
+	}
+}
+
+func TestBookBracketChildFill(t *testing.T) {
+	b := NewOrderBook(testMarket())
+	must(b.Execute(testOrder("b1", "carol", TypeBid, "5", "104")))
+	must(b.Execute(testOrder("a1", "carol", TypeAsk, "5", "106")))
+
+	// the take profit trades as soon as it is placed, cancelling the queued stop loss
+	entry := testOrder("e1", "alice", TypeBid, "1", "")
+	tp := testOrder("e2", "alice", TypeAsk, "1", "103")
+	sl := testStop(testOrder("e3", "alice", TypeAsk, "1", ""), "90")
+	events := must(b.ExecuteGroup(&OrderGroup{Id: "g1", Type: GroupBracket, Orders: []*Order{entry, tp, sl}}))
+
+	var cancelled bool
+	for _, ev := range events {
+		if ev.Order == nil || ev.Order.OrderId != "e3" {
+			continue
+		}
+		if cancelled || ev.Type != EventCancel || ev.Reason != "oco" {
+			t.Errorf("unexpected stop loss event %s after %v", ev, eventTypes(events))
+		}
+		cancelled = true
+	}
+	if !cancelled || sl.Status != OrderCancel || tp.Status != OrderDone || len(b.Stops) != 0 || len(b.Asks) != 1 {
+		t.Errorf("unexpected bracket state tp=%s sl=%s stops=%d", tp.Status, sl.Status, len(b.Stops))
+	}
+}
+
+func TestGroupJSON(t *testing.T) {
+	sl := testStop(testOrder("a2", "alice", TypeAsk, "1", ""), "90")
+	g := &OrderGroup{Id: "g1", Type: GroupOCO, Orders: []*Order{sl}}
+
+	data := must(json.Marshal(g))
+	var g2 *OrderGroup
+	if err := json.Unmarshal(data, &g2); err != nil {
+		t.Fatalf("failed to unmarshal group: %s", err)
+	}
+	if g2.Type != GroupOCO || !g2.Orders[0].Flags.Has(FlagStop) || g2.Orders[0].StopPrice.String() != "90.00000" {
+		t.Errorf("unexpected group after round trip: %s", data)
+	}
+}
+
+func TestBookTrailingStop(t *testing.T) {
+	b := NewOrderBook(testMarket())
+	trade := func(price string) {
+		must(b.Execute(testOrder("m", "maker", TypeBid, "0.1", price)))
+		must(b.Execute(testOrder("t", "taker", TypeAsk, "0.1", price)))
+	}
+	trade("100")
+
+	ts := testOrder("a1", "alice", TypeAsk, "1", "")
+	ts.Flags |= FlagStop
+	ts.TrailOffset = must(NewAmountFromString("5", 5))
+	must(b.Execute(ts))
+	if ts.TriggerPrice().String() != "95.00000" {
+		t.Fatalf("expected trigger price 95, got %s", ts.TriggerPrice())
+	}
+
+	trade("105")
+	trade("102")
+	if ts.TriggerPrice().String() != "100.00000" || ts.Status != OrderStop {
+		t.Fatalf("expected trigger price to ratchet up to 100, got %s", ts.TriggerPrice())
+	}
+
+	must(b.Execute(testOrder("b1", "bob", TypeBid, "2", "99")))
+	trade("100")
+	if ts.Status != OrderDone || ts.Flags.Has(FlagStop) {
+		t.Errorf("expected trailing stop to be triggered and executed, got %s", ts.Status)
+	}
+
+	bs := testOrder("b2", "bob", TypeBid, "1", "")
+	bs.Flags |= FlagStop
+	bs.TrailPercent = must(NewAmountFromString("10", 0))
+	bs.Trail(must(NewAmountFromString("100", 5)))
+	bs.Trail(must(NewAmountFromString("90", 5)))
+	bs.Trail(must(NewAmountFromString("95", 5)))
+	if bs.TriggerPrice().String() != "99.00000" {
+		t.Errorf("expected buy trailing stop at 99, got %s", bs.TriggerPrice())
+	}
+
+	// a triggered trailing stop limit order rests as a valid limit order
+	b = NewOrderBook(testMarket())
+	trade("100")
+	sl := testOrder("a2", "alice", TypeAsk, "1", "80")
+	sl.Flags |= FlagStop
+	sl.TrailOffset = must(NewAmountFromString("5", 5))
+	must(b.Execute(sl))
+	must(b.Execute(testOrder("m", "maker", TypeBid, "0.1", "95")))
+	events := must(b.Execute(testOrder("t", "taker", TypeAsk, "0.1", "95")))
+	if sl.Status != OrderOpen || len(b.Asks) != 1 || sl.IsValid() != nil {
+		t.Fatalf("expected triggered stop limit to rest, got %s (%v)", sl.Status, sl.IsValid())
+	}
+	for _, ev := range events {
+		if strings.Contains(ev.String(), "invalid") {
+			t.Errorf("unexpected event %s", ev)
+		}
+	}
+}
+
+func TestBookCollar(t *testing.T) {
+	m := testMarket()
+	m.CollarPercent = must(NewAmountFromString("2", 0))
+	b := NewOrderBook(m)
+
+	must(b.Execute(testOrder("a1", "alice", TypeAsk, "1", "100")))
+	must(b.Execute(testOrder("a2", "alice", TypeAsk, "1", "102")))
+	must(b.Execute(testOrder("a3", "alice", TypeAsk, "1", "103")))
+
+	mkt := testOrder("b1", "bob", TypeBid, "5", "")
+	events := must(b.Execute(mkt))
+
+	last := events[len(events)-1]
+	if mkt.Status != OrderCancel || last.Reason != "collar" {
+		t.Errorf("expected market order to be cancelled by collar, got %s", last)
+	}
+	if mkt.Amount.String() != "3.00000000" || len(b.Asks) != 1 || b.Asks[0].OrderId != "a3" {
+		t.Errorf("market order swept beyond collar, remaining %s", mkt.Amount)
+	}
+}
+
+func TestBookCircuitBreaker(t *testing.T) {
+	m := testMarket()
+	m.Bands = &PriceBands{
+		Reference:       must(NewAmountFromString("100", 5)),
+		StaticPercent:   must(NewAmountFromString("10", 0)),
+		DynamicPercent:  must(NewAmountFromString("5", 0)),
+		AuctionOnResume: true,
+	}
+	b := NewOrderBook(m)
+
+	_, err := b.Execute(testOrder("x", "alice", TypeAsk, "1", "120"))
+	var bandErr *PriceBandError
+	if !errors.As(err, &bandErr) || !errors.Is(err, ErrPriceOutOfBand) || bandErr.High.String() != "110.00000" {
+		t.Fatalf("expected price band error, got %v", err)
+	}
+
+	must(b.Execute(testOrder("a1", "alice", TypeAsk, "1", "100")))
+	must(b.Execute(testOrder("b1", "bob", TypeBid, "0.5", "100")))
+	must(b.Execute(testOrder("b2", "bob", TypeBid, "1", "96")))
+	must(b.Execute(testOrder("a2", "alice", TypeAsk, "1", "104")))
+	must(b.Execute(testOrder("b3", "bob", TypeBid, "1", "104")))
+
+	// last price is 104, selling at 96 would breach the dynamic band
+	mkt := testOrder("c1", "carol", TypeAsk, "1", "")
+	events := must(b.Execute(mkt))
+	if !b.Halted || mkt.Status != OrderCancel || events[1].Type != EventHalt || events[1].Price.String() != "96.00000" {
+		t.Fatalf("expected trading halt, got %v", events)
+	}
+
+	// orders rest without matching during the halt
+	must(b.Execute(testOrder("d1", "dave", TypeBid, "1", "105")))
+	if len(b.Bids) != 2 || b.Bids[0].Price.Cmp(b.Asks[0].Price) < 0 {
+		t.Fatalf("expected crossed book during halt")
+	}
+
+	events = b.Resume()
+	if b.Halted || events[0].Type != EventResume || events[0].Reason != "auction" {
+		t.Fatalf("unexpected resume events %v", events)
+	}
+	// both 104 and 105 execute 0.5 with a surplus of bids, so the highest price wins
+	if events[1].Type != EventTrade || events[1].Trade.Price.String() != "105.00000" || events[1].Trade.Amount.String() != "0.50000000" {
+		t.Errorf("unexpected auction trade %s", events[1])
+	}
+	if len(b.Asks) != 0 || b.Bids[0].Amount.String() != "0.50000000" {
+		t.Errorf("unexpected book after auction")
+	}
+}
+
+func TestBookCallAuction(t *testing.T) {
+	b := NewOrderBook(testMarket())
+	b.StartAuction()
+
+	must(b.Execute(testOrder("b1", "bob", TypeBid, "1", "102")))
+	must(b.Execute(testOrder("b2", "bob", TypeBid, "1", "101")))
+	must(b.Execute(testOrder("b3", "bob", TypeBid, "1", "99")))
+	must(b.Execute(testOrder("a1", "alice", TypeAsk, "1", "100")))
+	must(b.Execute(testOrder("a2", "alice", TypeAsk, "1", "101")))
+	must(b.Execute(testOrder("a3", "alice", TypeAsk, "1", "103")))
+
+	mkt := testOrder("c1", "carol", TypeBid, "1", "")
+	must(b.Execute(mkt))
+	if mkt.Status != OrderCancel {
+		t.Errorf("market orders can't participate in the call period")
+	}
+
+	price, volume := b.IndicativePrice()
+	if price.String() != "101.00000" || volume.String() != "2.00000000" {
+		t.Fatalf("unexpected indicative price %s volume %s", price, volume)
+	}
+
+	events := b.EndAuction()
+	var trades int
+	for _, ev := range events {
+		if ev.Type == EventTrade {
+			trades += 1
+			if ev.Trade.Price.String() != "101.00000" {
+				t.Errorf("auction trade at wrong price: %s", ev.Trade)
+			}
+		}
+	}
+	if trades != 2 || b.Auction {
+		t.Errorf("expected 2 trades, got %d", trades)
+	}
+	if len(b.Bids) != 1 || len(b.Asks) != 1 || b.Bids[0].OrderId != "b3" || b.Asks[0].OrderId != "a3" {
+		t.Errorf("unexpected remaining orders")
+	}
+
+	// back to continuous matching
+	must(b.Execute(testOrder("c2", "carol", TypeBid, "1", "103")))
+	if len(b.Asks) != 0 {
+		t.Errorf("expected continuous matching after auction")
+	}
+}
+
+func TestBookAuctionSelfTrade(t *testing.T) {
+	m := testMarket()
+	m.SelfTrade = SelfTradeCancelNewest
+	b := NewOrderBook(m)
+	b.StartAuction()
+
+	a1 := testOrder("a1", "alice", TypeAsk, "1", "100")
+	a2 := testOrder("a2", "alice", TypeBid, "1", "101")
+	must(b.Execute(a1))
+	must(b.Execute(a2))
+	must(b.Execute(testOrder("b1", "bob", TypeBid, "1", "100")))
+
+	// the auction price is 101, where alice would trade with herself
+	events := b.EndAuction()
+	if events[0].Price.String() != "101.00000" || a2.Status != OrderCancel {
+		t.Fatalf("expected self-trade prevention in auction, got %v", eventTypes(events))
+	}
+	var trades []*Trade
+	for _, ev := range events {
+		if ev.Type == EventTrade {
+			trades = append(trades, ev.Trade)
+		}
+	}
+	// the book is crossed again, and uncrossed with continuous matching
+	if len(trades) != 1 || trades[0].Bid.OrderId != "b1" || trades[0].Ask.OrderId != "a1" || trades[0].Price.String() != "100.00000" {
+		t.Errorf("unexpected trades %v", trades)
+	}
+	if len(b.Bids) != 0 || len(b.Asks) != 0 {
+		t.Errorf("unexpected remaining orders")
+	}
+}
+
+func TestBookAuctionHalt(t *testing.T) {
+	b := NewOrderBook(testMarket())
+	b.StartAuction()
+	must(b.Execute(testOrder("a1", "alice", TypeAsk, "1", "100")))
+	must(b.Execute(testOrder("b1", "bob", TypeBid, "1", "100")))
+
+	// no execution while halted, the book is uncrossed when trading resumes
+	b.Halt("manual")
+	events := b.EndAuction()
+	if len(events) != 1 || events[0].Type != EventAuctionEnd || events[0].Price != nil || b.Auction {
+		t.Fatalf("unexpected events ending auction while halted: %v", eventTypes(events))
+	}
+	events = b.Resume()
+	if len(events) < 2 || events[1].Type != EventTrade || len(b.Bids) != 0 {
+		t.Fatalf("expected uncrossing on resume, got %v", eventTypes(events))
+	}
+
+	// an auction price outside the bands halts trading
+	m := testMarket()
+	b = NewOrderBook(m)
+	b.StartAuction()
+	must(b.Execute(testOrder("a1", "alice", TypeAsk, "1", "120")))
+	must(b.Execute(testOrder("b1", "bob", TypeBid, "1", "120")))
+	m.Bands = &PriceBands{
+		Reference:     must(NewAmountFromString("100", 5)),
+		StaticPercent: must(NewAmountFromString("10", 0)),
+	}
+	events = b.EndAuction()
+	if !b.Halted || events[1].Type != EventHalt || events[1].Price.String() != "120.00000" || len(b.Bids) != 1 {
+		t.Errorf("expected halt on auction price outside bands, got %v", eventTypes(events))
+	}
+}
+
+func TestBookProRata(t *testing.T) {
+	b := NewOrderBook(testMarket())
+	b.Allocator = &ProRata{
+		Min:  must(NewAmountFromString("0.2", 8)),
+		Step: must(NewAmountFromString("0.1", 8)),
+	}
+
+	must(b.Execute(testOrder("a1", "alice", TypeAsk, "1", "100")))
+	must(b.Execute(testOrder("a2", "carol", TypeAsk, "3", "100")))
+	must(b.Execute(testOrder("a3", "dave", TypeAsk, "0.5", "100")))
+	must(b.Execute(testOrder("a4", "dave", TypeAsk, "1", "101")))
+
+	// 2 out of 4.5: a1=0.444 -> 0.4, a2=1.333 -> 1.3, a3=0.222 -> 0.2
+	// then 0.1 left allocated in order of priority to a1
+	events := must(b.Execute(testOrder("b1", "bob", TypeBid, "2", "101")))
+
+	fills := map[string]string{}
+	for _, ev := range events {
+		if ev.Type == EventTrade {
+			fills[ev.Trade.Ask.OrderId] = ev.Trade.Amount.String()
+		}
+	}
+	expect := map[string]string{"a1": "0.50000000", "a2": "1.30000000", "a3": "0.20000000"}
+	for id, amt := range expect {
+		if fills[id] != amt {
+			t.Errorf("expected %s to be allocated %s, got %s", id, amt, fills[id])
+		}
+	}
+	if len(fills) != 3 {
+		t.Errorf("unexpected fills %v", fills)
+	}
+
+	alloc := FIFO{}.Allocate(must(NewAmountFromString("1.5", 8)), b.Asks)
+	if alloc[0].String() != "0.50000000" || alloc[1].String() != "1.00000000" {
+		t.Errorf("unexpected FIFO allocation %v", alloc)
+	}
+
+	// a step finer than the amount precision means no rounding
+	fine := &ProRata{Step: must(NewAmountFromString("0.000000001", 9))}
+	alloc = fine.Allocate(must(NewAmountFromString("1", 8)), []*Order{
+		testOrder("a5", "alice", TypeAsk, "1", "101"),
+		testOrder("a6", "carol", TypeAsk, "2", "101"),
+	})
+	if alloc[0].String() != "0.33333334" || alloc[1].String() != "0.66666666" {
+		t.Errorf("unexpected allocation with fine step %v", alloc)
+	}
+}
+
+func TestBookFillOrKill(t *testing.T) {
+	b := NewOrderBook(testMarket())
+	must(b.Execute(testOrder("a1", "alice", TypeAsk, "1", "100")))
+	must(b.Execute(testOrder("a2", "alice", TypeAsk, "1", "101")))
+
+	fok := testOrder("b1", "bob", TypeBid, "2.5", "101")
+	fok.Flags |= FlagFillOrKill
+
+	fill := must(b.Simulate(fok))
+	if fill.Complete || len(fill.Trades) != 2 || fill.Amount.String() != "2.00000000" || fill.Spent.String() != "201.00000" {
+		t.Errorf("unexpected simulation result %+v", fill)
+	}
+
+	events := must(b.Execute(fok))
+	if fok.Status != OrderCancel || events[len(events)-1].Reason != "fok" || len(events) != 2 {
+		t.Errorf("expected FOK order to be cancelled without trades, got %v", eventTypes(events))
+	}
+	if b.Asks[0].Amount.String() != "1.00000000" || fok.Amount.String() != "2.50000000" {
+		t.Errorf("simulation must not modify orders")
+	}
+
+	// spend 150 USD: 1 BTC @ 100 then 0.49504950 BTC @ 101, leaving dust
+	spend := testOrder("b2", "bob", TypeBid, "1", "")
+	spend.Amount = nil
+	spend.SpendLimit = must(NewAmountFromString("150", 5))
+	spend.Flags |= FlagFillOrKill
+	if fill := must(b.Simulate(spend)); !fill.Complete || fill.Amount.String() != "1.49504950" {
+		t.Errorf("expected spend limit order to be fillable, got %+v", fill)
+	}
+	must(b.Execute(spend))
+	if spend.Status != OrderDone {
+		t.Errorf("expected spend limit FOK order to execute, got %s", spend.Status)
+	}
+}
+
+func TestBookFillOrKillSelfTrade(t *testing.T) {
+	tests := []struct {
+		mode     SelfTradeMode
+		complete bool
+		asks     int
+	}{
+		{SelfTradeNone, true, 1},
+		{SelfTradeCancelNewest, false, 3},
+		{SelfTradeCancelOldest, true, 0},
+		{SelfTradeCancelBoth, false, 3},
+		{SelfTradeDecrement, false, 3},
+	}
+
+	for _, test := range tests {
+		m := testMarket()
+		m.SelfTrade = test.mode
+		b := NewOrderBook(m)
+		must(b.Execute(testOrder("a1", "bob", TypeAsk, "1", "100")))
+		must(b.Execute(testOrder("a2", "alice", TypeAsk, "1", "100")))
+		must(b.Execute(testOrder("a3", "carol", TypeAsk, "1", "100")))
+
+		fok := testOrder("b1", "alice", TypeBid, "2", "100")
+		fok.Flags |= FlagFillOrKill
+		if fill := must(b.Simulate(fok)); fill.Complete != test.complete {
+			t.Errorf("%s: expected simulation complete=%v, got %+v", test.mode, test.complete, fill)
+		}
+
+		must(b.Execute(fok))
+		if test.complete && fok.Status != OrderDone {
+			t.Errorf("%s: expected FOK order to execute, got %s", test.mode, fok.Status)
+		}
+		if !test.complete && (fok.Status != OrderCancel || fok.Amount.String() != "2.00000000") {
+			t.Errorf("%s: expected FOK order to be cancelled without trades, got %s amount %s", test.mode, fok.Status, fok.Amount)
+		}
+		if len(b.Asks) != test.asks {
+			t.Errorf("%s: expected %d remaining asks, got %d", test.mode, test.asks, len(b.Asks))
+		}
+	}
+}
+
+func TestBookSimulatePrecision(t *testing.T) {
+	b := NewOrderBook(testMarket())
+	must(b.Execute(testOrder("a1", "alice", TypeAsk, "1", "100")))
+
+	o := testOrder("b1", "bob", TypeBid, "1", "")
+	o.Amount = must(NewAmountFromString("0.5", 3))
+	o.Price = must(NewAmountFromString("100", 2))
+	amount := o.Amount.String()
+	if fill := must(b.Simulate(o)); !fill.Complete || fill.Amount.String() != "0.50000000" || o.Amount.String() != amount {
+		t.Errorf("unexpected simulation result %+v", fill)
+	}
+
+	o.Price = must(NewAmountFromString("100.123456", 6))
+	if _, err := b.Simulate(o); !errors.Is(err, ErrAmountPrecision) {
+		t.Errorf("expected ErrAmountPrecision, got %v", err)
+	}
+}
@@ -1,19 +1,160 @@
 package ellipxobj
 
+import (
+	"bytes"
+	"sort"
+)
+
 // Checkpoint represents a snapshot of an order book at a specific point in time.
 // Checkpoints can be used for verification, recovery, or synchronization
 // of order book state between different systems.
 //
 // Checkpoints form a chain through PrevEpoch and PrevHash fields,
-// allowing validation of the integrity of the order book history.
+// allowing validation of the integrity of the order book history, and
+// can be signed by the matching engine to prove their authenticity.
 type Checkpoint struct {
-	Pair       PairName `json:"pair"`      // The trading pair this checkpoint belongs to
-	Epoch      uint64   `json:"epoch"`     // Current checkpoint sequence number
-	PrevEpoch  uint64   `json:"prev"`      // Previous checkpoint sequence number (for chain validation)
-	PrevHash   []byte   `json:"prev_hash"` // Hash of the previous checkpoint (for integrity verification)
-	Point      TimeId   `json:"point"`     // Timestamp of when this checkpoint was created
-	OrderSum   []byte   `json:"in_sum"`    // Cryptographic hash representing all orders in the book
-	OrderCount uint64   `json:"in_cnt"`    // Total number of orders included in this checkpoint
-	Bids       []*Order `json:"bids"`      // Buy orders in the book (sorted by price, highest first)
-	Asks       []*Order `json:"asks"`      // Sell orders in the book (sorted by price, lowest first)
+	Pair       PairName `json:"pair"`            // The trading pair this checkpoint belongs to
+	Epoch      uint64   `json:"epoch"`           // Current checkpoint sequence number
+	PrevEpoch  uint64   `json:"prev"`            // Previous checkpoint sequence number (for chain validation)
+	PrevHash   []byte   `json:"prev_hash"`       // Hash of the previous checkpoint (for integrity verification)
+	Point      TimeId   `json:"point"`           // Timestamp of when this checkpoint was created
+	OrderSum   []byte   `json:"in_sum"`          // Merkle root of all orders in the book (see Sum)
+	OrderCount uint64   `json:"in_cnt"`          // Total number of orders included in this checkpoint
+	Bids       []*Order `json:"bids"`            // Buy orders in the book (sorted by price, highest first)
+	Asks       []*Order `json:"asks"`            // Sell orders in the book (sorted by price, lowest first)
+	Stops      []*Order `json:"stops,omitempty"` // Stop orders waiting for their trigger price
+
+	Groups    []*OrderGroup `json:"groups,omitempty"`     // Active order groups, sorted by id
+	LastPrice *Amount       `json:"last_price,omitempty"` // Price of the last trade
+	Halted    bool          `json:"halted,omitempty"`     // True if trading is halted
+	Auction   bool          `json:"auction,omitempty"`    // True if the book is in the call period of an auction
+
+	KeyId     string `json:"kid,omitempty"` // Id of the key that signed this checkpoint
+	Signature []byte `json:"sig,omitempty"` // Ed25519 signature of Hash (see Sign)
+}
+
+// orders returns the orders of the checkpoint by side: bids, asks, then stops
+func (c *Checkpoint) orders() [][]*Order {
+	return [][]*Order{c.Bids, c.Asks, c.Stops}
+}
+
+// Public returns a copy of the checkpoint where orders are replaced by their public
+// view (see Order.Public). The returned checkpoint is fit for publication but can't
+// be used for recovery since it lacks the hidden quantity of iceberg orders.
+func (c *Checkpoint) Public() *Checkpoint {
+	res := &Checkpoint{}
+	*res = *c
+	res.Bids = make([]*Order, len(c.Bids))
+	for n, o := range c.Bids {
+		res.Bids[n] = o.Public()
+	}
+	res.Asks = make([]*Order, len(c.Asks))
+	for n, o := range c.Asks {
+		res.Asks[n] = o.Public()
+	}
+	if c.Stops != nil {
+		res.Stops = make([]*Order, len(c.Stops))
+		for n, o := range c.Stops {
+			res.Stops[n] = o.Public()
+		}
+	}
+	if c.Groups != nil {
+		res.Groups = make([]*OrderGroup, len(c.Groups))
+		for n, g := range c.Groups {
+			res.Groups[n] = g.dup()
+			for i, o := range g.Orders {
+				res.Groups[n].Orders[i] = o.Public()
+			}
+		}
+	}
+	return res
+}
+
+// Sum returns the hash of the orders of the checkpoint, as stored in OrderSum. It
+// is the root of a Merkle tree whose leaves are the canonical encoding of each order
+// (see Order.Bytes), bids first then asks and stops, so the inclusion of an order
+// can be proven without revealing the others (see Proof).
+func (c *Checkpoint) Sum() []byte {
+	return merkleRoot(c.leaves())
+}
+
+// Checkpoint returns a checkpoint of the state of the book: resting and stop
+// orders, order groups, last trade price and trading state. Point is the last id
+// generated by the book, so that events generated after the checkpoint have a
+// higher id. Epoch and the chain fields are left for the caller to set.
+func (b *OrderBook) Checkpoint() *Checkpoint {
+	c := &Checkpoint{
+		Pair:      b.Market.Pair,
+		Point:     b.ids.Last,
+		Bids:      dupOrders(b.Bids),
+		Asks:      dupOrders(b.Asks),
+		LastPrice: b.LastPrice.Dup(),
+		Halted:    b.Halted,
+		Auction:   b.Auction,
+	}
+	if len(b.Stops) > 0 {
+		c.Stops = dupOrders(b.Stops)
+	}
+	for _, g := range b.groups {
+		c.Groups = append(c.Groups, g.dup())
+	}
+	sort.Slice(c.Groups, func(i, j int) bool { return c.Groups[i].Id < c.Groups[j].Id })
+
+	c.OrderCount = uint64(len(c.Bids) + len(c.Asks) + len(c.Stops))
+	c.OrderSum = c.Sum()
+	return c
+}
+
+func dupOrders(orders []*Order) []*Order {
+	res := make([]*Order, len(orders))
+	for n, o := range orders {
+		res[n] = o.Dup()
+	}
+	return res
+}
+
+// RestoreOrderBook returns a book for market m in the state of checkpoint c. Ids
+// generated by the book will be after c.Point. If c has an OrderSum, it must match
+// its orders. Orders of groups that are in the book are linked to the book's
+// orders, the others (for example the pending orders of a bracket) are copied.
+func RestoreOrderBook(m *Market, c *Checkpoint) (*OrderBook, error) {
+	if c.Pair != m.Pair {
+		return nil, ErrPairMismatch
+	}
+	if c.OrderSum != nil && !bytes.Equal(c.OrderSum, c.Sum()) {
+		return nil, ErrOrderSumMismatch
+	}
+
+	b := NewOrderBook(m)
+	point := c.Point
+	b.ids.Unique(&point)
+	for _, o := range c.Bids {
+		b.Bids = append(b.Bids, o.Dup())
+	}
+	for _, o := range c.Asks {
+		b.Asks = append(b.Asks, o.Dup())
+	}
+	for _, o := range c.Stops {
+		b.Stops = append(b.Stops, o.Dup())
+	}
+	b.LastPrice = c.LastPrice.Dup()
+	b.Halted = c.Halted
+	b.Auction = c.Auction
+
+	if len(c.Groups) > 0 {
+		b.groups = make(map[string]*OrderGroup)
+	}
+	for _, cg := range c.Groups {
+		g := cg.dup()
+		for n, o := range g.Orders {
+			if o.Unique == nil {
+				continue
+			}
+			if m := b.find(*o.Unique); m != nil {
+				g.Orders[n] = m
+			}
+		}
+		b.groups[g.Id] = g
+	}
+	return b, nil
 }
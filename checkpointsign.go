@@ -0,0 +1,110 @@
+package ellipxobj
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Hash returns the canonical hash of the checkpoint, covering all its fields except
+// Signature. Orders are covered through OrderSum, and order groups through their
+// full encoding. This is the value signed by Sign and expected in the PrevHash of
+// the next checkpoint.
+func (c *Checkpoint) Hash() []byte {
+	buf := []byte{0x00} // version
+	buf = appendString(buf, c.Pair[0])
+	buf = appendString(buf, c.Pair[1])
+	buf = binary.AppendUvarint(buf, c.Epoch)
+	buf = binary.AppendUvarint(buf, c.PrevEpoch)
+	buf = appendBytes(buf, c.PrevHash)
+	buf = c.Point.Bytes(buf)
+	buf = appendBytes(buf, c.OrderSum)
+	buf = binary.AppendUvarint(buf, c.OrderCount)
+	buf = c.appendState(buf)
+	buf = appendString(buf, c.KeyId)
+
+	h := sha256.Sum256(buf)
+	return h[:]
+}
+
+// appendState appends the encoding of the book state held by the checkpoint
+// besides its orders
+func (c *Checkpoint) appendState(buf []byte) []byte {
+	buf = appendAmount(buf, c.LastPrice)
+	var flags byte
+	if c.Halted {
+		flags |= 1
+	}
+	if c.Auction {
+		flags |= 2
+	}
+	buf = append(buf, flags)
+	buf = binary.AppendUvarint(buf, uint64(len(c.Groups)))
+	for _, g := range c.Groups {
+		buf = appendGroup(buf, g)
+	}
+	return buf
+}
+
+// Link sets the epoch and chain fields of c so it follows prev
+func (c *Checkpoint) Link(prev *Checkpoint) {
+	c.Epoch = prev.Epoch + 1
+	c.PrevEpoch = prev.Epoch
+	c.PrevHash = prev.Hash()
+}
+
+// Sign sets KeyId and signs the checkpoint with key. Any later change to the
+// checkpoint invalidates the signature.
+func (c *Checkpoint) Sign(keyId string, key ed25519.PrivateKey) {
+	c.KeyId = keyId
+	c.Signature = ed25519.Sign(key, c.Hash())
+}
+
+// Verify checks that the checkpoint is signed by one of the trusted keys, indexed
+// by key id, and that its orders match the signed OrderCount and OrderSum. Since
+// public checkpoints (see Public) hide part of iceberg orders, they only verify if
+// they contain no iceberg order.
+func (c *Checkpoint) Verify(keys map[string]ed25519.PublicKey) error {
+	if c.Signature == nil {
+		return ErrSignatureMissing
+	}
+	pub, ok := keys[c.KeyId]
+	if !ok {
+		return ErrKeyNotTrusted
+	}
+	if !ed25519.Verify(pub, c.Hash(), c.Signature) {
+		return ErrSignatureNotValid
+	}
+	if c.OrderCount != uint64(len(c.Bids)+len(c.Asks)+len(c.Stops)) {
+		return ErrOrderCountMismatch
+	}
+	if !bytes.Equal(c.OrderSum, c.Sum()) {
+		return ErrOrderSumMismatch
+	}
+	return nil
+}
+
+// VerifyChain checks that each checkpoint follows the previous one (same pair,
+// PrevEpoch and PrevHash matching the previous checkpoint, which has a lower Point)
+// and is valid (see Verify).
+func VerifyChain(keys map[string]ed25519.PublicKey, chain []*Checkpoint) error {
+	for n, c := range chain {
+		if err := c.Verify(keys); err != nil {
+			return err
+		}
+		if n == 0 {
+			continue
+		}
+		prev := chain[n-1]
+		if c.Pair != prev.Pair || c.PrevEpoch != prev.Epoch || c.Epoch <= prev.Epoch || c.Point.Cmp(prev.Point) <= 0 || !bytes.Equal(c.PrevHash, prev.Hash()) {
+			return ErrChainBroken
+		}
+	}
+	return nil
+}
+
+func appendBytes(buf []byte, v []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(v)))
+	return append(buf, v...)
+}
@@ -0,0 +1,62 @@
+package ellipxobj
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestCheckpointSign(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	_, other, _ := ed25519.GenerateKey(nil)
+	keys := map[string]ed25519.PublicKey{"engine": pub}
+
+	b := NewOrderBook(testMarket())
+	must(b.Execute(testOrder("a1", "alice", TypeAsk, "1", "100")))
+	c1 := b.Checkpoint()
+	c1.Sign("engine", key)
+	must(b.Execute(testOrder("b1", "bob", TypeBid, "0.5", "100")))
+	ice := testOrder("b2", "bob", TypeBid, "5", "98")
+	ice.Display = must(NewAmountFromString("1", 8))
+	must(b.Execute(ice))
+	c2 := b.Checkpoint()
+	c2.Link(c1)
+	c2.Sign("engine", key)
+
+	if err := VerifyChain(keys, []*Checkpoint{c1, c2}); err != nil {
+		t.Errorf("chain verification failed: %s", err)
+	}
+	// a public checkpoint keeps its signature, but hides the iceberg quantity
+	if err := c1.Public().Verify(keys); err != nil {
+		t.Errorf("public checkpoint verification failed: %s", err)
+	}
+	if err := c2.Public().Verify(keys); err != ErrOrderSumMismatch {
+		t.Errorf("expected order sum mismatch, got %v", err)
+	}
+	if err := VerifyChain(keys, []*Checkpoint{c2, c1}); err != ErrChainBroken {
+		t.Errorf("expected broken chain, got %v", err)
+	}
+
+	// orders do not match the signed count and sum
+	tampered := *c2
+	tampered.Asks = nil
+	if err := VerifyChain(keys, []*Checkpoint{c1, &tampered}); err != ErrOrderCountMismatch {
+		t.Errorf("expected order count mismatch, got %v", err)
+	}
+	tampered.Asks = c2.Bids
+	if err := tampered.Verify(keys); err != ErrOrderSumMismatch {
+		t.Errorf("expected order sum mismatch, got %v", err)
+	}
+
+	c2.OrderCount += 1
+	if err := c2.Verify(keys); err != ErrSignatureNotValid {
+		t.Errorf("expected invalid signature, got %v", err)
+	}
+	c2.Sign("other", other)
+	if err := c2.Verify(keys); err != ErrKeyNotTrusted {
+		t.Errorf("expected untrusted key, got %v", err)
+	}
+	c2.Signature = nil
+	if err := c2.Verify(keys); err != ErrSignatureMissing {
+		t.Errorf("expected missing signature, got %v", err)
+	}
+}
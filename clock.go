@@ -0,0 +1,40 @@
+package ellipxobj
+
+import "time"
+
+// Clock is a source of time. Objects that need the current time can be given a
+// Clock so their results are reproducible, for example when replaying orders.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a Clock returning the system time
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ManualClock is a Clock that only changes when set, for tests and replays
+type ManualClock struct {
+	T time.Time
+}
+
+func (c *ManualClock) Now() time.Time {
+	return c.T
+}
+
+// NewManualClock returns a ManualClock set to t
+func NewManualClock(t time.Time) *ManualClock {
+	return &ManualClock{T: t}
+}
+
+// Set sets the time returned by the clock
+func (c *ManualClock) Set(t time.Time) {
+	c.T = t
+}
+
+// Advance moves the clock forward by d
+func (c *ManualClock) Advance(d time.Duration) {
+	c.T = c.T.Add(d)
+}
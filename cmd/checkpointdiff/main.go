@@ -0,0 +1,70 @@
+// Command checkpointdiff compares the orders of two checkpoints of the same pair,
+// stored either as JSON or as snapshots, and reports the differences. It exits with
+// status 1 if the checkpoints differ.
+//
+// Usage:
+//
+//	checkpointdiff [-json] old new
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/EllipX/ellipxobj"
+)
+
+func main() {
+	asJson := flag.Bool("json", false, "output the differences as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-json] old new\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	d, err := diff(flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "checkpointdiff: %s\n", err)
+		os.Exit(2)
+	}
+
+	if *asJson {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(d)
+	} else {
+		for _, o := range d.Removed {
+			fmt.Printf("- %s %s\n", o.Unique, o)
+		}
+		for _, o := range d.Added {
+			fmt.Printf("+ %s %s\n", o.Unique, o)
+		}
+		for _, c := range d.Changed {
+			fmt.Printf("~ %s %s (%s)\n", c.New.Unique, c.New, strings.Join(c.Fields, ", "))
+		}
+		fmt.Println(d.Summary())
+	}
+
+	if !d.IsEmpty() {
+		os.Exit(1)
+	}
+}
+
+func diff(oldPath, newPath string) (*ellipxobj.CheckpointDiff, error) {
+	a, err := ellipxobj.ReadCheckpointFile(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ellipxobj.ReadCheckpointFile(newPath)
+	if err != nil {
+		return nil, err
+	}
+	return ellipxobj.DiffCheckpoints(a, b)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/EllipX/ellipxobj"
+)
+
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	marketFile := fs.String("market", "", "market spec (JSON)")
+	fs.Parse(args)
+	if *marketFile == "" {
+		return errors.New("-market is required")
+	}
+
+	m := &ellipxobj.Market{}
+	if err := readJSON(*marketFile, m); err != nil {
+		return err
+	}
+	data, err := readInput(fs.Args())
+	if err != nil {
+		return err
+	}
+	o := &ellipxobj.Order{}
+	if err := json.Unmarshal(data, o); err != nil {
+		return err
+	}
+
+	if err := m.Validate(o); err != nil {
+		return fmt.Errorf("order %s is not valid for %s: %w", o.OrderId, m.Pair, err)
+	}
+	fmt.Printf("order %s is valid for %s\n", o.OrderId, m.Pair)
+	return nil
+}
+
+// keysFlag collects trusted public keys given as id=hex
+type keysFlag map[string]ed25519.PublicKey
+
+func (k keysFlag) String() string {
+	return fmt.Sprintf("%d keys", len(k))
+}
+
+func (k keysFlag) Set(v string) error {
+	id, key, ok := strings.Cut(v, "=")
+	if !ok {
+		return errors.New("key must be id=hex")
+	}
+	pub, err := hex.DecodeString(key)
+	if err != nil {
+		return err
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("key %s must be %d bytes", id, ed25519.PublicKeySize)
+	}
+	k[id] = pub
+	return nil
+}
+
+func runVerify(args []string) error {
+	keys := keysFlag{}
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.Var(keys, "key", "trusted public key as id=hex (can be repeated)")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("expected checkpoint files")
+	}
+
+	var chain []*ellipxobj.Checkpoint
+	for _, path := range fs.Args() {
+		c, err := ellipxobj.ReadCheckpointFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if n := len(c.Bids) + len(c.Asks) + len(c.Stops); uint64(n) != c.OrderCount {
+			return fmt.Errorf("%s: %d orders, expected %d: %w", path, n, c.OrderCount, ellipxobj.ErrOrderCountMismatch)
+		}
+		if !bytes.Equal(c.Sum(), c.OrderSum) {
+			return fmt.Errorf("%s: %w", path, ellipxobj.ErrOrderSumMismatch)
+		}
+		chain = append(chain, c)
+	}
+
+	if err := ellipxobj.VerifyChain(keys, chain); err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d checkpoints verified, epochs %d to %d\n", chain[0].Pair, len(chain), chain[0].Epoch, chain[len(chain)-1].Epoch)
+	return nil
+}
+
+func runJournal(args []string) error {
+	fs := flag.NewFlagSet("journal", flag.ExitOnError)
+	from := fs.String("from", "", "only print records after this TimeId")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("expected a journal file")
+	}
+
+	var point ellipxobj.TimeId
+	if *from != "" {
+		t, err := ellipxobj.ParseTimeId(*from)
+		if err != nil {
+			return err
+		}
+		point = *t
+	}
+
+	return ellipxobj.ReadJournal(fs.Arg(0), point, func(rec *ellipxobj.JournalRecord) error {
+		if rec.Command != nil {
+			fmt.Printf("%s > %s\n", rec.Command.Id, rec.Command)
+		} else {
+			fmt.Printf("%s   %s\n", rec.Event.Id, rec.Event)
+		}
+		return nil
+	})
+}
+
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	marketFile := fs.String("market", "", "market spec (JSON)")
+	checkpointFile := fs.String("checkpoint", "", "checkpoint to start from (empty book if not set)")
+	out := fs.String("out", "", "write the resulting checkpoint to this file (JSON)")
+	fs.Parse(args)
+	if *marketFile == "" || fs.NArg() != 1 {
+		return errors.New("-market and a journal file are required")
+	}
+
+	m := &ellipxobj.Market{}
+	if err := readJSON(*marketFile, m); err != nil {
+		return err
+	}
+	c := &ellipxobj.Checkpoint{Pair: m.Pair}
+	if *checkpointFile != "" {
+		var err error
+		if c, err = ellipxobj.ReadCheckpointFile(*checkpointFile); err != nil {
+			return err
+		}
+	}
+
+	// commands are applied again, and must generate the recorded events
+	b, events, err := ellipxobj.ReplayJournal(m, c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	res := b.Checkpoint()
+	fmt.Printf("%s: replayed %d events\n", m.Pair, len(events))
+	fmt.Printf("%d bids, %d asks, %d stops, order sum %x\n", len(res.Bids), len(res.Asks), len(res.Stops), res.OrderSum)
+	if *out == "" {
+		return nil
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*out, data, 0644)
+}
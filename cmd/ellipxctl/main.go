@@ -0,0 +1,86 @@
+// Command ellipxctl is a debugging tool for ellipxobj objects and files.
+//
+// Usage:
+//
+//	ellipxctl <command> [arguments]
+//
+// Run "ellipxctl help" for the list of commands.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(args []string) error
+}
+
+var commands []*command
+
+func main() {
+	commands = []*command{
+		{"order", "[file]", "parse, validate and pretty-print an order (JSON)", runOrder},
+		{"trade", "[file]", "parse and pretty-print a trade (JSON)", runTrade},
+		{"amount", "[-exp n] value", "convert an amount between string, JSON and binary (0x hex) forms", runAmount},
+		{"timeid", "value", "convert a TimeId between string, JSON and binary (0x hex) forms", runTimeId},
+		{"validate", "-market file [file]", "validate an order against a market spec (JSON)", runValidate},
+		{"verify", "-key id=hex... file...", "verify a chain of checkpoints (JSON or snapshots)", runVerify},
+		{"journal", "[-from timeid] file", "print the events of a journal", runJournal},
+		{"replay", "-market file [-checkpoint file] [-out file] journal", "rebuild a book from a checkpoint and a journal", runReplay},
+	}
+
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" {
+		usage()
+		return
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "ellipxctl %s: %s\n", c.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "ellipxctl: unknown command %q\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: ellipxctl <command> [arguments]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n            %s\n", c.name, c.usage, c.help)
+	}
+}
+
+// readInput returns the contents of the file named by the first argument, or of
+// stdin if there is no argument or it is "-"
+func readInput(args []string) ([]byte, error) {
+	if len(args) == 0 || args[0] == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(args[0])
+}
+
+// readJSON decodes the JSON file at path into v
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// printJSON prints v as indented JSON
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
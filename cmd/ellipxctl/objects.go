@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/EllipX/ellipxobj"
+)
+
+func runOrder(args []string) error {
+	data, err := readInput(args)
+	if err != nil {
+		return err
+	}
+	o := &ellipxobj.Order{}
+	if err := json.Unmarshal(data, o); err != nil {
+		return err
+	}
+
+	fmt.Printf("order:  %s\n", o)
+	if err := o.IsValid(); err != nil {
+		fmt.Printf("valid:  no (%s)\n", err)
+	} else {
+		fmt.Printf("valid:  yes\n")
+	}
+	return printJSON(o)
+}
+
+func runTrade(args []string) error {
+	data, err := readInput(args)
+	if err != nil {
+		return err
+	}
+	t := &ellipxobj.Trade{}
+	if err := json.Unmarshal(data, t); err != nil {
+		return err
+	}
+	if t.Amount == nil || t.Price == nil {
+		return errors.New("trade amount and price are required")
+	}
+
+	fmt.Printf("trade:  %s\n", t)
+	return printJSON(t)
+}
+
+func runAmount(args []string) error {
+	fs := flag.NewFlagSet("amount", flag.ExitOnError)
+	exp := fs.Int("exp", -1, "convert to this number of decimals (rounding if needed)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("expected one value")
+	}
+
+	in := fs.Arg(0)
+	a := &ellipxobj.Amount{}
+	var err error
+	switch {
+	case strings.HasPrefix(in, "0x"):
+		var data []byte
+		if data, err = hex.DecodeString(in[2:]); err == nil {
+			err = a.UnmarshalBinary(data)
+		}
+	case strings.HasPrefix(in, "{"), strings.HasPrefix(in, `"`):
+		err = json.Unmarshal([]byte(in), a)
+	default:
+		a, err = ellipxobj.NewAmountFromString(in, 0)
+	}
+	if err != nil {
+		return err
+	}
+	if *exp >= 0 {
+		a.SetExp(*exp)
+	}
+
+	j, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("string: %s\n", a)
+	fmt.Printf("json:   %s\n", j)
+	fmt.Printf("binary: 0x%x\n", a.Bytes())
+	return nil
+}
+
+func runTimeId(args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected one value")
+	}
+
+	in := args[0]
+	t := &ellipxobj.TimeId{}
+	var err error
+	switch {
+	case strings.HasPrefix(in, "0x"):
+		var data []byte
+		if data, err = hex.DecodeString(in[2:]); err == nil {
+			err = t.UnmarshalBinary(data)
+		}
+	case strings.HasPrefix(in, `"`):
+		err = json.Unmarshal([]byte(in), t)
+	default:
+		t, err = ellipxobj.ParseTimeId(in)
+	}
+	if err != nil {
+		return err
+	}
+
+	j, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("string: %s\n", t)
+	fmt.Printf("json:   %s\n", j)
+	fmt.Printf("binary: 0x%x\n", t.Bytes(nil))
+	fmt.Printf("time:   %s\n", t.Time().UTC().Format(time.RFC3339Nano))
+	return nil
+}
@@ -0,0 +1,234 @@
+package ellipxobj
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CommandType is the kind of operation described by a Command
+type CommandType int
+
+const (
+	CommandInvalid      CommandType = -1
+	CommandExecute      CommandType = iota // OrderBook.Execute of Order
+	CommandExecuteGroup                    // OrderBook.ExecuteGroup of Group
+	CommandAmend                           // OrderBook.Amend with Order
+	CommandCancel                          // OrderBook.Cancel of Target
+	CommandExpire                          // OrderBook.Expire at Time
+	CommandHalt                            // OrderBook.Halt with Reason
+	CommandResume                          // OrderBook.Resume
+	CommandAuctionStart                    // OrderBook.StartAuction
+	CommandAuctionEnd                      // OrderBook.EndAuction
+)
+
+func (t CommandType) String() string {
+	switch t {
+	case CommandExecute:
+		return "execute"
+	case CommandExecuteGroup:
+		return "execute_group"
+	case CommandAmend:
+		return "amend"
+	case CommandCancel:
+		return "cancel"
+	case CommandExpire:
+		return "expire"
+	case CommandHalt:
+		return "halt"
+	case CommandResume:
+		return "resume"
+	case CommandAuctionStart:
+		return "auction_start"
+	case CommandAuctionEnd:
+		return "auction_end"
+	default:
+		return "invalid"
+	}
+}
+
+func (t CommandType) IsValid() bool {
+	switch t {
+	case CommandExecute, CommandExecuteGroup, CommandAmend, CommandCancel, CommandExpire,
+		CommandHalt, CommandResume, CommandAuctionStart, CommandAuctionEnd:
+		return true
+	default:
+		return false
+	}
+}
+
+func CommandTypeByString(s string) CommandType {
+	switch s {
+	case "execute":
+		return CommandExecute
+	case "execute_group":
+		return CommandExecuteGroup
+	case "amend":
+		return CommandAmend
+	case "cancel":
+		return CommandCancel
+	case "expire":
+		return CommandExpire
+	case "halt":
+		return CommandHalt
+	case "resume":
+		return CommandResume
+	case "auction_start":
+		return CommandAuctionStart
+	case "auction_end":
+		return CommandAuctionEnd
+	default:
+		return CommandInvalid
+	}
+}
+
+func (t CommandType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *CommandType) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	v := CommandTypeByString(s)
+	if v == CommandInvalid {
+		return fmt.Errorf("invalid command type %q", s)
+	}
+	*t = v
+	return nil
+}
+
+// Command is an operation submitted to an OrderBook. Commands are what a Journal
+// records as inputs of the book: applying the same commands to a book in the same
+// state produces the same orders, even for operations whose parameters do not
+// appear in events (groups, expiry time, halts and auctions).
+type Command struct {
+	Id     *TimeId     `json:"id,omitempty"` // set by the book when the command is applied
+	Type   CommandType `json:"type"`
+	Order  *Order      `json:"order,omitempty"`  // CommandExecute and CommandAmend
+	Group  *OrderGroup `json:"group,omitempty"`  // CommandExecuteGroup
+	Target *TimeId     `json:"target,omitempty"` // CommandCancel
+	Time   *TimeId     `json:"time,omitempty"`   // CommandExpire
+	Reason string      `json:"reason,omitempty"` // CommandHalt
+}
+
+func (c *Command) String() string {
+	switch {
+	case c.Order != nil:
+		return fmt.Sprintf("%s %s", c.Type, c.Order)
+	case c.Group != nil:
+		return fmt.Sprintf("%s %s group %s of %d orders", c.Type, c.Group.Type, c.Group.Id, len(c.Group.Orders))
+	case c.Target != nil:
+		return fmt.Sprintf("%s %s", c.Type, c.Target)
+	case c.Time != nil:
+		return fmt.Sprintf("%s at %s", c.Type, c.Time)
+	case c.Reason != "":
+		return fmt.Sprintf("%s (%s)", c.Type, c.Reason)
+	default:
+		return c.Type.String()
+	}
+}
+
+// Apply executes command c by calling the method of the book matching c.Type.
+//
+// The orders of c are not modified: the book executes copies of them, and c only
+// receives its Id (if not set yet) and the Unique ids allocated to its orders.
+// While c is applied, the Clock of the book is set to the time of c.Id, so all the
+// ids generated (orders, trades, events, replenished iceberg slices...) follow c.Id
+// and applying c again on a book in the same state produces the same events and
+// the same book, whatever the time.
+func (b *OrderBook) Apply(c *Command) ([]*Event, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	if c.Id == nil {
+		c.Id = b.newId("command")
+	} else {
+		// make sure ids we generate from now on are after this command
+		t := *c.Id
+		b.ids.Unique(&t)
+	}
+
+	clock := b.Clock
+	b.Clock = NewManualClock(c.Id.Time())
+	defer func() { b.Clock = clock }()
+
+	switch c.Type {
+	case CommandExecute:
+		o := c.Order.Dup()
+		events, err := b.Execute(o)
+		if err == nil {
+			recordUnique(c.Order, o.Unique)
+		}
+		return events, err
+	case CommandExecuteGroup:
+		g := &OrderGroup{Id: c.Group.Id, Type: c.Group.Type}
+		for _, o := range c.Group.Orders {
+			g.Orders = append(g.Orders, o.Dup())
+		}
+		events, err := b.ExecuteGroup(g)
+		if err == nil {
+			for n, o := range g.Orders {
+				recordUnique(c.Group.Orders[n], o.Unique)
+			}
+		}
+		return events, err
+	case CommandAmend:
+		events, err := b.Amend(c.Order.Dup())
+		for _, ev := range events {
+			if ev.Type == EventAmend && ev.Reason == "priority_lost" {
+				recordUnique(c.Order, ev.Order.Unique)
+			}
+		}
+		return events, err
+	case CommandCancel:
+		return b.Cancel(*c.Target)
+	case CommandExpire:
+		return b.Expire(*c.Time), nil
+	case CommandHalt:
+		return b.Halt(c.Reason), nil
+	case CommandResume:
+		return b.Resume(), nil
+	case CommandAuctionStart:
+		return b.StartAuction(), nil
+	default: // CommandAuctionEnd
+		return b.EndAuction(), nil
+	}
+}
+
+// check returns ErrCommandNotValid if c is missing the parameters of its type
+func (c *Command) check() error {
+	switch c.Type {
+	case CommandExecute, CommandAmend:
+		if c.Order == nil {
+			return ErrCommandNotValid
+		}
+	case CommandExecuteGroup:
+		if c.Group == nil {
+			return ErrCommandNotValid
+		}
+	case CommandCancel:
+		if c.Target == nil {
+			return ErrCommandNotValid
+		}
+	case CommandExpire:
+		if c.Time == nil {
+			return ErrCommandNotValid
+		}
+	default:
+		if !c.Type.IsValid() {
+			return ErrCommandNotValid
+		}
+	}
+	return nil
+}
+
+// recordUnique sets the Unique id of o to a copy of id if o has none
+func recordUnique(o *Order, id *TimeId) {
+	if o.Unique != nil || id == nil {
+		return
+	}
+	t := *id
+	o.Unique = &t
+}
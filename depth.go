@@ -0,0 +1,44 @@
+package ellipxobj
+
+// DepthLevel is the aggregated visible quantity of all orders at a given price
+type DepthLevel struct {
+	Price  *Amount `json:"price"`
+	Amount *Amount `json:"amount"`
+	Count  int     `json:"count"` // number of orders at this price
+}
+
+// Depth is a public snapshot of the book aggregated by price level. Only the
+// visible quantity of iceberg orders is included.
+type Depth struct {
+	Pair PairName      `json:"pair"`
+	Bids []*DepthLevel `json:"bids"` // highest price first
+	Asks []*DepthLevel `json:"asks"` // lowest price first
+}
+
+// Depth returns a snapshot of up to levels price levels on each side of the book.
+// If levels is zero or negative, all levels are returned.
+func (b *OrderBook) Depth(levels int) *Depth {
+	return &Depth{
+		Pair: b.Market.Pair,
+		Bids: depthLevels(b.Bids, levels),
+		Asks: depthLevels(b.Asks, levels),
+	}
+}
+
+func depthLevels(side []*Order, levels int) []*DepthLevel {
+	res := []*DepthLevel{}
+	var cur *DepthLevel
+
+	for _, o := range side {
+		if cur == nil || cur.Price.Cmp(o.Price) != 0 {
+			if levels > 0 && len(res) == levels {
+				break
+			}
+			cur = &DepthLevel{Price: o.Price.Dup(), Amount: NewAmount(0, o.Amount.exp)}
+			res = append(res, cur)
+		}
+		cur.Amount = cur.Amount.Add(cur.Amount, o.VisibleAmount())
+		cur.Count += 1
+	}
+	return res
+}
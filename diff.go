@@ -0,0 +1,119 @@
+package ellipxobj
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// OrderChange is an order present in two checkpoints with different values
+type OrderChange struct {
+	Old    *Order   `json:"old"`
+	New    *Order   `json:"new"`
+	Fields []string `json:"fields"` // Names of the fields that differ, such as "amount", "status" or "version"
+}
+
+// CheckpointDiff lists the differences between the orders of two checkpoints.
+// Orders are identified by their BrokerId and OrderId, so that orders with a new
+// Unique id (such as replenished iceberg orders) are reported as changed.
+type CheckpointDiff struct {
+	Pair      PairName       `json:"pair"`
+	Added     []*Order       `json:"added"`     // Orders only in the new checkpoint
+	Removed   []*Order       `json:"removed"`   // Orders only in the old checkpoint
+	Changed   []*OrderChange `json:"changed"`   // Orders in both checkpoints with different values
+	Unchanged int            `json:"unchanged"` // Number of identical orders
+}
+
+// DiffCheckpoints compares the orders of checkpoints a (old) and b (new), which
+// must be for the same pair. Results follow the order of the checkpoints, bids first
+// then asks and stops.
+func DiffCheckpoints(a, b *Checkpoint) (*CheckpointDiff, error) {
+	if a.Pair != b.Pair {
+		return nil, ErrPairMismatch
+	}
+	d := &CheckpointDiff{Pair: a.Pair}
+
+	old := make(map[brokerOrder]*Order)
+	for _, side := range a.orders() {
+		for _, o := range side {
+			old[brokerOrder{o.BrokerId, o.OrderId}] = o
+		}
+	}
+
+	for _, side := range b.orders() {
+		for _, o := range side {
+			id := brokerOrder{o.BrokerId, o.OrderId}
+			prev, ok := old[id]
+			if !ok {
+				d.Added = append(d.Added, o)
+				continue
+			}
+			delete(old, id)
+			if fields := orderDiff(prev, o); fields != nil {
+				d.Changed = append(d.Changed, &OrderChange{Old: prev, New: o, Fields: fields})
+			} else {
+				d.Unchanged += 1
+			}
+		}
+	}
+
+	for _, side := range a.orders() {
+		for _, o := range side {
+			if _, ok := old[brokerOrder{o.BrokerId, o.OrderId}]; ok {
+				d.Removed = append(d.Removed, o)
+			}
+		}
+	}
+	return d, nil
+}
+
+// IsEmpty returns true if both checkpoints hold the same orders
+func (d *CheckpointDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Summary returns a one line summary of the differences
+func (d *CheckpointDiff) Summary() string {
+	return fmt.Sprintf("%s: %d added, %d removed, %d changed, %d unchanged", d.Pair, len(d.Added), len(d.Removed), len(d.Changed), d.Unchanged)
+}
+
+// orderDiff returns the names of the fields that differ between a and b, or nil if
+// they are identical
+func orderDiff(a, b *Order) []string {
+	if bytes.Equal(a.Bytes(nil), b.Bytes(nil)) {
+		return nil
+	}
+
+	var res []string
+	add := func(name string, same bool) {
+		if !same {
+			res = append(res, name)
+		}
+	}
+	add("amount", sameAmount(a.Amount, b.Amount))
+	add("visible", sameAmount(a.Visible, b.Visible))
+	add("spend_limit", sameAmount(a.SpendLimit, b.SpendLimit))
+	add("price", sameAmount(a.Price, b.Price))
+	add("status", a.Status == b.Status)
+	add("version", a.Version == b.Version)
+	add("flags", a.Flags == b.Flags)
+	add("uniq", sameTimeId(a.Unique, b.Unique))
+	add("priority", sameTimeId(a.Priority, b.Priority))
+	if res == nil {
+		res = []string{"other"}
+	}
+	return res
+}
+
+func sameAmount(a, b *Amount) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return bytes.Equal(a.Bytes(), b.Bytes())
+}
+
+func sameTimeId(a, b *TimeId) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
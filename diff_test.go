@@ -0,0 +1,32 @@
+package ellipxobj
+
+import (
+	"testing"
+)
+
+func TestDiffCheckpoints(t *testing.T) {
+	b := NewOrderBook(testMarket())
+	must(b.Execute(testOrder("b1", "bob", TypeBid, "1", "99")))
+	must(b.Execute(testOrder("a1", "alice", TypeAsk, "1", "101")))
+	must(b.Execute(testOrder("a2", "alice", TypeAsk, "1", "102")))
+	c1 := b.Checkpoint()
+
+	must(b.Execute(testOrder("b2", "carol", TypeBid, "0.4", "101")))
+	must(b.Cancel(*b.Asks[1].Unique))
+	must(b.Execute(testOrder("b3", "bob", TypeBid, "2", "98")))
+	c2 := b.Checkpoint()
+
+	d := must(DiffCheckpoints(c1, c2))
+	if len(d.Added) != 1 || d.Added[0].OrderId != "b3" || len(d.Removed) != 1 || d.Removed[0].OrderId != "a2" || d.Unchanged != 1 {
+		t.Errorf("unexpected diff %s", d.Summary())
+	}
+	if len(d.Changed) != 1 || d.Changed[0].New.OrderId != "a1" || d.Changed[0].Fields[0] != "amount" {
+		t.Errorf("unexpected changes %+v", d.Changed)
+	}
+	if d.Summary() != "BTC_USD: 1 added, 1 removed, 1 changed, 1 unchanged" {
+		t.Errorf("unexpected summary %s", d.Summary())
+	}
+	if d = must(DiffCheckpoints(c2, c2)); !d.IsEmpty() {
+		t.Errorf("checkpoint must not differ from itself")
+	}
+}
@@ -8,5 +8,33 @@ var (
 	ErrOrderTypeNotValid   = errors.New("order type is not valid")
 	ErrOrderStatusNotValid = errors.New("order status is not valid")
 	ErrOrderNeedsAmount    = errors.New("order amount or spend limit is required")
+	ErrTimeInForceNotValid = errors.New("order time in force is not valid")
+	ErrOrderExpiryMissing  = errors.New("good till date order requires an expiry time")
+	ErrDisplayNotValid     = errors.New("display amount must be positive and requires a limit price")
+	ErrStopPriceMissing    = errors.New("stop order requires a stop price")
+	ErrTrailNotValid       = errors.New("trailing stop requires a stop flag and one positive offset")
+	ErrPriceOutOfBand      = errors.New("price is outside of price bands")
+	ErrNoRoute             = errors.New("no route found between assets")
+	ErrJournalCorrupt      = errors.New("journal record is corrupted")
+	ErrCommandNotValid     = errors.New("command is not valid")
+	ErrSnapshotFormat      = errors.New("invalid snapshot data")
+	ErrOrderSumMismatch    = errors.New("order sum does not match checkpoint orders")
+	ErrOrderCountMismatch  = errors.New("order count does not match checkpoint orders")
+	ErrReplayDiverged      = errors.New("replay diverged from recorded history")
+	ErrSignatureMissing    = errors.New("signature is missing")
+	ErrSignatureNotValid   = errors.New("signature is not valid")
+	ErrKeyNotTrusted       = errors.New("signing key is not trusted")
+	ErrKeyNotValid         = errors.New("signing key is not valid")
+	ErrChainBroken         = errors.New("checkpoint does not follow previous checkpoint")
+	ErrOrderStale          = errors.New("order request time is outside of the allowed window")
+	ErrOrderReplayed       = errors.New("order id was already used by this broker")
+	ErrGroupIdMissing      = errors.New("order group id is required")
+	ErrGroupNotValid       = errors.New("order group is not valid")
+	ErrGroupExists         = errors.New("order group already exists")
 	ErrAmountParseFailed   = errors.New("failed to parse provided amount")
+	ErrAmountPrecision     = errors.New("amount has more decimals than allowed")
+	ErrPairMismatch        = errors.New("order pair does not match market")
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrAmendTargetMismatch = errors.New("amendment target does not match order")
+	ErrAmendNotCompatible  = errors.New("amendment is not compatible with order")
 )
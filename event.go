@@ -0,0 +1,136 @@
+package ellipxobj
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// EventType is the kind of an Event generated by an OrderBook
+type EventType int
+
+const (
+	EventInvalid      EventType = -1
+	EventAccept       EventType = iota // order was accepted by the book
+	EventOpen                          // order was placed in the book as a resting order
+	EventTrade                         // a trade happened
+	EventDone                          // order was fully executed
+	EventCancel                        // order was cancelled, see Reason
+	EventSelfTrade                     // a match was prevented because both orders have the same owner
+	EventAmend                         // order was modified, Reason is "priority_lost" if it lost its time priority
+	EventReplenish                     // a new slice of an iceberg order was shown, with a new Priority
+	EventTrigger                       // a stop order was triggered and is being executed
+	EventHalt                          // trading was halted, Price is set if caused by a trade outside price bands
+	EventResume                        // trading resumed, Reason is "auction" if the book is uncrossed by auction
+	EventAuctionStart                  // the call period of an auction started
+	EventAuctionEnd                    // the auction ended, Price is the uncrossing price if any
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventAccept:
+		return "accept"
+	case EventOpen:
+		return "open"
+	case EventTrade:
+		return "trade"
+	case EventDone:
+		return "done"
+	case EventCancel:
+		return "cancel"
+	case EventSelfTrade:
+		return "self_trade"
+	case EventAmend:
+		return "amend"
+	case EventReplenish:
+		return "replenish"
+	case EventTrigger:
+		return "trigger"
+	case EventHalt:
+		return "halt"
+	case EventResume:
+		return "resume"
+	case EventAuctionStart:
+		return "auction_start"
+	case EventAuctionEnd:
+		return "auction_end"
+	default:
+		return "invalid"
+	}
+}
+
+func EventTypeByString(s string) EventType {
+	switch s {
+	case "accept":
+		return EventAccept
+	case "open":
+		return EventOpen
+	case "trade":
+		return EventTrade
+	case "done":
+		return EventDone
+	case "cancel":
+		return EventCancel
+	case "self_trade":
+		return EventSelfTrade
+	case "amend":
+		return EventAmend
+	case "replenish":
+		return EventReplenish
+	case "trigger":
+		return EventTrigger
+	case "halt":
+		return EventHalt
+	case "resume":
+		return EventResume
+	case "auction_start":
+		return EventAuctionStart
+	case "auction_end":
+		return EventAuctionEnd
+	default:
+		return EventInvalid
+	}
+}
+
+func (t EventType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *EventType) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	v := EventTypeByString(s)
+	if v == EventInvalid {
+		return fmt.Errorf("invalid event type %q", s)
+	}
+	*t = v
+	return nil
+}
+
+// Event describes something that happened in an OrderBook. Order contains a copy of
+// the order as it was when the event happened, so events can be stored or
+// transmitted without being affected by later changes to the book.
+type Event struct {
+	Id     *TimeId    `json:"id"`
+	Type   EventType  `json:"type"`
+	Order  *Order     `json:"order,omitempty"`  // Order concerned by this event
+	Trade  *Trade     `json:"trade,omitempty"`  // Trade, for EventTrade
+	Other  *OrderMeta `json:"other,omitempty"`  // Counterparty order, for EventSelfTrade
+	Price  *Amount    `json:"price,omitempty"`  // Price that caused a halt, or auction price
+	Reason string     `json:"reason,omitempty"` // Why this happened (for example "ioc" or "self_trade" for EventCancel)
+}
+
+func (e *Event) String() string {
+	switch {
+	case e.Trade != nil:
+		return fmt.Sprintf("%s: %s", e.Type, e.Trade)
+	case e.Order == nil:
+		return fmt.Sprintf("%s (%s)", e.Type, e.Reason)
+	case e.Reason != "":
+		return fmt.Sprintf("%s (%s): %s", e.Type, e.Reason, e.Order)
+	default:
+		return fmt.Sprintf("%s: %s", e.Type, e.Order)
+	}
+}
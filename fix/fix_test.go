@@ -0,0 +1,203 @@
+package fix
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EllipX/ellipxobj"
+)
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func must2[A, B any](a A, b B, err error) (A, B) {
+	if err != nil {
+		panic(err)
+	}
+	return a, b
+}
+
+func raw(s string) []byte {
+	return []byte(strings.ReplaceAll(s, "|", "\x01"))
+}
+
+func TestMessage(t *testing.T) {
+	m := NewMessage(MsgNewOrderSingle).Add(TagClOrdID, "o1").Add(TagSide, "1").Add(TagText, "")
+	enc := m.Encode()
+	if string(enc) != string(raw("8=FIX.4.4|9=16|35=D|11=o1|54=1|10=001|")) {
+		t.Errorf("unexpected encoding %s", m)
+	}
+
+	res := must(Decode(enc))
+	if res.Type() != MsgNewOrderSingle || len(res.Fields) != 3 {
+		t.Errorf("unexpected decoded message %+v", res)
+	}
+
+	if _, err := Decode(raw("8=FIX.4.4|9=16|35=D|11=o2|54=1|10=001|")); err != ErrBadChecksum {
+		t.Errorf("expected bad checksum, got %v", err)
+	}
+	if _, err := Decode(raw("8=FIX.4.4|9=15|35=D|11=o1|54=1|10=000|")); err != ErrBadBodyLength {
+		t.Errorf("expected bad body length, got %v", err)
+	}
+	if _, err := Decode(raw("8=FIX.4.4|9=16|35=D|11=o1|54=1")); err != ErrMalformed {
+		t.Errorf("expected malformed message, got %v", err)
+	}
+}
+
+func TestDecodeOrder(t *testing.T) {
+	m := must(Decode(NewMessage(MsgNewOrderSingle).
+		Add(TagSenderCompID, "broker1").
+		Add(TagAccount, "alice").
+		Add(TagClOrdID, "o1").
+		Add(TagSymbol, "BTC/USD").
+		Add(TagSide, "2").
+		Add(TagOrdType, "2").
+		Add(TagOrderQty, "1.5").
+		Add(TagPrice, "50000.25").
+		Add(TagTimeInForce, "6").
+		Add(TagExpireTime, "20301231-23:59:59.000").
+		Encode()))
+
+	o, _ := must2(DecodeOrder(m))
+	if o.Type != ellipxobj.TypeAsk || o.Pair != ellipxobj.Pair("BTC", "USD") || o.BrokerId != "broker1" || o.UserId != "alice" {
+		t.Errorf("unexpected order %+v", o)
+	}
+	if o.Amount.String() != "1.5" || o.Price.String() != "50000.25" || o.TimeInForce != ellipxobj.TimeInForceGTD || o.Expires.Unix != 1924991999 {
+		t.Errorf("unexpected order values %s %+v", o, o.Expires)
+	}
+
+	// encoding the order gives back the same order
+	o2, _ := must2(DecodeOrder(must(Decode(NewOrderSingle(o).Encode()))))
+	if string(o2.Bytes(nil)) != string(o.Bytes(nil)) {
+		t.Errorf("order round trip failed:\n%+v\n%+v", o, o2)
+	}
+
+	// immediate or cancel stop limit replacing an order
+	o.Flags = ellipxobj.FlagStop | ellipxobj.FlagImmediateOrCancel
+	o.StopPrice = ellipxobj.NewAmount(49000, 0)
+	o.TimeInForce, o.Expires = ellipxobj.TimeInForceGTC, nil
+	o.Target = &ellipxobj.TimeId{Type: "order", Unix: 1700000000, Index: 3}
+	r, orig := must2(DecodeOrder(must(Decode(OrderCancelReplaceRequest(o, "o0").Encode()))))
+	if orig != "o0" || r.Target.Cmp(*o.Target) != 0 || r.Flags != o.Flags || r.StopPrice.String() != "49000" {
+		t.Errorf("unexpected replace order %+v", r)
+	}
+
+	m = NewMessage(MsgNewOrderSingle).Add(TagSymbol, "BTC/USD").Add(TagSide, "3")
+	if _, _, err := DecodeOrder(m); !errors.Is(err, ErrFieldNotValid) {
+		t.Errorf("expected invalid side, got %v", err)
+	}
+
+	// timestamps with and without milliseconds
+	for _, ts := range []string{"20231114-22:13:20", "20231114-22:13:20.250"} {
+		m = NewMessage(MsgNewOrderSingle).
+			Add(TagSenderCompID, "broker1").
+			Add(TagClOrdID, "o2").
+			Add(TagSymbol, "BTC/USD").
+			Add(TagSide, "1").
+			Add(TagOrdType, "2").
+			Add(TagOrderQty, "1").
+			Add(TagPrice, "50000").
+			Add(TagTransactTime, ts).
+			Add(TagTimeInForce, "6").
+			Add(TagExpireTime, ts)
+		o, _ := must2(DecodeOrder(m))
+		if o.RequestTime != 1700000000 || o.Expires.Unix != 1700000000 {
+			t.Errorf("unexpected times for %s: %d %s", ts, o.RequestTime, o.Expires)
+		}
+	}
+	m = NewMessage(MsgNewOrderSingle).Add(TagSymbol, "BTC/USD").Add(TagSide, "1").Add(TagTransactTime, "2023-11-14 22:13:20")
+	if _, _, err := DecodeOrder(m); !errors.Is(err, ErrFieldNotValid) {
+		t.Errorf("expected invalid transact time, got %v", err)
+	}
+}
+
+func TestDecodeCancel(t *testing.T) {
+	target := &ellipxobj.TimeId{Type: "order", Unix: 1700000000}
+	c := &CancelRequest{ClOrdID: "c1", OrigClOrdID: "o1", BrokerId: "broker1", Pair: ellipxobj.Pair("BTC", "USD"), Type: ellipxobj.TypeBid, Target: target}
+	msg := must(Decode(OrderCancelRequest(c, time.Unix(1700000001, 0)).Encode()))
+	res := must(DecodeCancel(msg))
+	if *res.Target != *target || res.OrigClOrdID != "o1" || res.BrokerId != "broker1" || res.Type != ellipxobj.TypeBid {
+		t.Errorf("unexpected cancel %+v", res)
+	}
+	if v := get(msg, TagTransactTime); v != "20231114-22:13:21.000" {
+		t.Errorf("unexpected transact time %s", v)
+	}
+	if _, _, err := DecodeOrder(msg); err != ErrUnsupported {
+		t.Errorf("expected unsupported message, got %v", err)
+	}
+}
+
+func TestReporter(t *testing.T) {
+	m := &ellipxobj.Market{Pair: ellipxobj.Pair("BTC", "USD"), AmountExp: 8, PriceExp: 2}
+	b := ellipxobj.NewOrderBook(m)
+	r := NewReporter("EXCH")
+
+	order := func(id string, typ ellipxobj.OrderType, amount, price string) *ellipxobj.Order {
+		o := ellipxobj.NewOrder(m.Pair, typ).SetId(id, "broker1")
+		o.UserId = id
+		o.Amount = must(ellipxobj.NewAmountFromString(amount, 8))
+		o.Price = must(ellipxobj.NewAmountFromString(price, 2))
+		return o
+	}
+
+	reports := r.Reports(must(b.Execute(order("a1", ellipxobj.TypeAsk, "2", "100"))))
+	if len(reports) != 1 || get(reports[0], TagExecType) != ExecNew || get(reports[0], TagLeavesQty) != "2.00000000" {
+		t.Fatalf("unexpected reports %v", reports)
+	}
+
+	reports = r.Reports(must(b.Execute(order("b1", ellipxobj.TypeBid, "0.5", "101"))))
+	if len(reports) != 3 {
+		t.Fatalf("unexpected reports %v", reports)
+	}
+	bid, ask := reports[1], reports[2]
+	if get(bid, TagOrdStatus) != StatusFilled || get(bid, TagLeavesQty) != "0.00000000" || get(bid, TagLastPx) != "100.00" || get(bid, TagAvgPx) != "100.00" {
+		t.Errorf("unexpected bid report %s", bid)
+	}
+	if get(ask, TagOrdStatus) != StatusPartiallyFilled || get(ask, TagLeavesQty) != "1.50000000" || get(ask, TagCumQty) != "0.50000000" || get(ask, TagTargetCompID) != "broker1" {
+		t.Errorf("unexpected ask report %s", ask)
+	}
+
+	reports = r.Reports(must(b.Cancel(*b.Asks[0].Unique)))
+	if len(reports) != 1 || get(reports[0], TagOrdStatus) != StatusCanceled || get(reports[0], TagCumQty) != "0.50000000" || get(reports[0], TagText) != "user" {
+		t.Errorf("unexpected cancel reports %v", reports)
+	}
+	must(Decode(reports[0].Encode()))
+
+	// the OrderID stays the same when the order is replenished or loses its priority
+	ice := order("a2", ellipxobj.TypeAsk, "3", "102")
+	ice.Display = must(ellipxobj.NewAmountFromString("1", 8))
+	reports = r.Reports(must(b.Execute(ice)))
+	id := get(reports[0], TagOrderID)
+	reports = r.Reports(must(b.Execute(order("b2", ellipxobj.TypeBid, "1", "102"))))
+	if len(reports) != 3 || get(reports[2], TagOrderID) != id || b.Asks[0].Unique.String() != id {
+		t.Fatalf("unexpected reports after replenish %v", reports)
+	}
+	amend := order("a2", ellipxobj.TypeAsk, "2", "103")
+	amend.Target = r.Target(must(ellipxobj.ParseTimeId(id)))
+	reports = r.Reports(must(b.Amend(amend)))
+	if len(reports) != 1 || get(reports[0], TagExecType) != ExecReplaced || get(reports[0], TagOrderID) != id {
+		t.Fatalf("unexpected amend reports %v", reports)
+	}
+	reports = r.Reports(must(b.Cancel(*r.Target(must(ellipxobj.ParseTimeId(id))))))
+	if len(reports) != 1 || get(reports[0], TagOrderID) != id || get(reports[0], TagCumQty) != "1.00000000" {
+		t.Errorf("unexpected cancel reports %v", reports)
+	}
+
+	// events without id use the clock of the reporter
+	r.Clock = ellipxobj.NewManualClock(time.Unix(1700000001, 0))
+	reports = r.Reports([]*ellipxobj.Event{{Type: ellipxobj.EventAccept, Order: order("c1", ellipxobj.TypeBid, "1", "90")}})
+	if get(reports[0], TagTransactTime) != "20231114-22:13:21.000" {
+		t.Errorf("unexpected transact time %s", reports[0])
+	}
+}
+
+func get(m *Message, tag int) string {
+	v, _ := m.Get(tag)
+	return v
+}
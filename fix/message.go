@@ -0,0 +1,191 @@
+// Package fix maps ellipxobj orders and events to FIX 4.4 messages: client
+// requests (NewOrderSingle, OrderCancelRequest, OrderCancelReplaceRequest) are
+// decoded into orders, and book events are encoded as ExecutionReports.
+//
+// Only message encoding is provided. Session handling (logon, sequence numbers,
+// heartbeats, resend requests) is left to the caller, which should add the
+// corresponding header fields such as MsgSeqNum and SendingTime.
+package fix
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// BeginString is the FIX version implemented by this package
+const BeginString = "FIX.4.4"
+
+// SOH is the FIX field delimiter
+const SOH = 0x01
+
+// Tags used by this package
+const (
+	TagAccount      = 1
+	TagAvgPx        = 6
+	TagBeginString  = 8
+	TagBodyLength   = 9
+	TagCheckSum     = 10
+	TagClOrdID      = 11
+	TagCumQty       = 14
+	TagExecID       = 17
+	TagLastPx       = 31
+	TagLastQty      = 32
+	TagMsgSeqNum    = 34
+	TagMsgType      = 35
+	TagOrderID      = 37
+	TagOrderQty     = 38
+	TagOrdStatus    = 39
+	TagOrdType      = 40
+	TagOrigClOrdID  = 41
+	TagPrice        = 44
+	TagSenderCompID = 49
+	TagSendingTime  = 52
+	TagSide         = 54
+	TagSymbol       = 55
+	TagTargetCompID = 56
+	TagText         = 58
+	TagTimeInForce  = 59
+	TagTransactTime = 60
+	TagStopPx       = 99
+	TagMaxFloor     = 111
+	TagExpireTime   = 126
+	TagExecType     = 150
+	TagLeavesQty    = 151
+	TagCashOrderQty = 152
+)
+
+// Message types
+const (
+	MsgNewOrderSingle            = "D"
+	MsgOrderCancelRequest        = "F"
+	MsgOrderCancelReplaceRequest = "G"
+	MsgExecutionReport           = "8"
+)
+
+var (
+	ErrMalformed     = errors.New("malformed FIX message")
+	ErrBadChecksum   = errors.New("FIX message checksum mismatch")
+	ErrBadBodyLength = errors.New("FIX message body length mismatch")
+	ErrFieldMissing  = errors.New("required FIX field is missing")
+	ErrFieldNotValid = errors.New("FIX field value is not valid")
+	ErrUnsupported   = errors.New("unsupported FIX message type")
+)
+
+// Field is a single tag=value pair
+type Field struct {
+	Tag   int
+	Value string
+}
+
+// Message is a FIX message. Fields holds the message type and all fields up to
+// the checksum, excluding BeginString, BodyLength and CheckSum which are handled
+// by Encode and Decode.
+type Message struct {
+	Fields []Field
+}
+
+// NewMessage returns a message of the given type
+func NewMessage(msgType string) *Message {
+	return &Message{Fields: []Field{{TagMsgType, msgType}}}
+}
+
+// Type returns the MsgType of the message
+func (m *Message) Type() string {
+	v, _ := m.Get(TagMsgType)
+	return v
+}
+
+// Get returns the value of the first field with the given tag
+func (m *Message) Get(tag int) (string, bool) {
+	for _, f := range m.Fields {
+		if f.Tag == tag {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// Add appends a field to the message. Empty values are ignored.
+func (m *Message) Add(tag int, value string) *Message {
+	if value != "" {
+		m.Fields = append(m.Fields, Field{tag, value})
+	}
+	return m
+}
+
+// Encode returns the wire representation of the message, with BeginString,
+// BodyLength and CheckSum
+func (m *Message) Encode() []byte {
+	body := &bytes.Buffer{}
+	for _, f := range m.Fields {
+		body.WriteString(strconv.Itoa(f.Tag))
+		body.WriteByte('=')
+		body.WriteString(f.Value)
+		body.WriteByte(SOH)
+	}
+
+	res := &bytes.Buffer{}
+	fmt.Fprintf(res, "8=%s\x019=%d\x01", BeginString, body.Len())
+	res.Write(body.Bytes())
+	fmt.Fprintf(res, "10=%03d\x01", checksum(res.Bytes()))
+	return res.Bytes()
+}
+
+func (m *Message) String() string {
+	return string(bytes.ReplaceAll(m.Encode(), []byte{SOH}, []byte{'|'}))
+}
+
+// Decode parses a single FIX message, checking its BeginString, BodyLength and
+// CheckSum
+func Decode(data []byte) (*Message, error) {
+	var fields []Field
+	var bodyStart, sumStart int // offsets of the body (after BodyLength) and of CheckSum
+	for pos := 0; pos < len(data); {
+		end := bytes.IndexByte(data[pos:], SOH)
+		if end == -1 {
+			return nil, ErrMalformed
+		}
+		tag, value, ok := bytes.Cut(data[pos:pos+end], []byte{'='})
+		if !ok {
+			return nil, ErrMalformed
+		}
+		t, err := strconv.Atoi(string(tag))
+		if err != nil || t <= 0 {
+			return nil, ErrMalformed
+		}
+		switch t {
+		case TagCheckSum:
+			sumStart = pos
+		case TagBodyLength:
+			bodyStart = pos + end + 1
+		}
+		fields = append(fields, Field{t, string(value)})
+		pos += end + 1
+	}
+
+	n := len(fields)
+	if n < 4 || fields[0].Tag != TagBeginString || fields[1].Tag != TagBodyLength || fields[2].Tag != TagMsgType || fields[n-1].Tag != TagCheckSum {
+		return nil, ErrMalformed
+	}
+	if fields[0].Value != BeginString {
+		return nil, fmt.Errorf("%w: unsupported version %s", ErrMalformed, fields[0].Value)
+	}
+	if ln, err := strconv.Atoi(fields[1].Value); err != nil || ln != sumStart-bodyStart {
+		return nil, ErrBadBodyLength
+	}
+	if sum, err := strconv.Atoi(fields[n-1].Value); err != nil || sum != checksum(data[:sumStart]) {
+		return nil, ErrBadChecksum
+	}
+	return &Message{Fields: fields[2 : n-1]}, nil
+}
+
+// checksum returns the sum of all bytes modulo 256
+func checksum(data []byte) int {
+	var sum int
+	for _, c := range data {
+		sum += int(c)
+	}
+	return sum % 256
+}
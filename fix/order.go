@@ -0,0 +1,294 @@
+package fix
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/EllipX/ellipxobj"
+)
+
+// TimestampFormat is the format of FIX UTCTimestamp fields, as encoded
+const TimestampFormat = "20060102-15:04:05.000"
+
+// timestampLayout parses UTCTimestamp fields with or without fractional seconds
+const timestampLayout = "20060102-15:04:05"
+
+// CancelRequest is a decoded OrderCancelRequest
+type CancelRequest struct {
+	ClOrdID     string             // Id of the cancel request
+	OrigClOrdID string             // OrderId of the order to cancel
+	BrokerId    string             // SenderCompID of the request
+	Pair        ellipxobj.PairName // Symbol
+	Type        ellipxobj.OrderType
+	Target      *ellipxobj.TimeId // Unique id of the order to cancel, if OrderID was given
+}
+
+// Symbol returns the FIX symbol of a pair, such as BTC/USD
+func Symbol(p ellipxobj.PairName) string {
+	return p[0] + "/" + p[1]
+}
+
+// ParseSymbol parses a symbol such as BTC/USD or BTC_USD
+func ParseSymbol(s string) (ellipxobj.PairName, error) {
+	base, quote, ok := strings.Cut(s, "/")
+	if !ok {
+		return ellipxobj.ParsePairName(s)
+	}
+	return ellipxobj.Pair(base, quote), nil
+}
+
+// DecodeOrder returns the order of a NewOrderSingle or OrderCancelReplaceRequest
+// message. The order's BrokerId is the SenderCompID and its UserId the Account.
+//
+// For replace requests, the order's Target is set from OrderID (the Unique id of
+// the order, as sent in execution reports) and the original ClOrdID is returned.
+// The caller must resolve OrigClOrdID itself if OrderID is missing.
+func DecodeOrder(m *Message) (*ellipxobj.Order, string, error) {
+	switch m.Type() {
+	case MsgNewOrderSingle, MsgOrderCancelReplaceRequest:
+	default:
+		return nil, "", ErrUnsupported
+	}
+
+	pair, typ, err := decodeInstrument(m)
+	if err != nil {
+		return nil, "", err
+	}
+	o := ellipxobj.NewOrder(pair, typ)
+	o.OrderId, _ = m.Get(TagClOrdID)
+	o.BrokerId, _ = m.Get(TagSenderCompID)
+	o.UserId, _ = m.Get(TagAccount)
+	if t, ok := m.Get(TagTransactTime); ok {
+		ts, err := time.Parse(timestampLayout, t)
+		if err != nil {
+			return nil, "", fieldError(TagTransactTime, t)
+		}
+		o.RequestTime = uint64(ts.Unix())
+	}
+
+	if o.Amount, err = getAmount(m, TagOrderQty); err != nil {
+		return nil, "", err
+	}
+	if o.SpendLimit, err = getAmount(m, TagCashOrderQty); err != nil {
+		return nil, "", err
+	}
+	if o.Display, err = getAmount(m, TagMaxFloor); err != nil {
+		return nil, "", err
+	}
+
+	ordType, _ := m.Get(TagOrdType)
+	switch ordType {
+	case "1": // market
+	case "2": // limit
+		o.Price, err = getAmount(m, TagPrice)
+	case "3": // stop
+		o.Flags |= ellipxobj.FlagStop
+		o.StopPrice, err = getAmount(m, TagStopPx)
+	case "4": // stop limit
+		o.Flags |= ellipxobj.FlagStop
+		if o.Price, err = getAmount(m, TagPrice); err == nil {
+			o.StopPrice, err = getAmount(m, TagStopPx)
+		}
+	default:
+		err = fieldError(TagOrdType, ordType)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	tif, _ := m.Get(TagTimeInForce)
+	switch tif {
+	case "", "1": // good till cancel
+	case "0":
+		o.TimeInForce = ellipxobj.TimeInForceDay
+	case "3":
+		o.Flags |= ellipxobj.FlagImmediateOrCancel
+	case "4":
+		o.Flags |= ellipxobj.FlagFillOrKill
+	case "6":
+		o.TimeInForce = ellipxobj.TimeInForceGTD
+		exp, _ := m.Get(TagExpireTime)
+		ts, err := time.Parse(timestampLayout, exp)
+		if err != nil {
+			return nil, "", fieldError(TagExpireTime, exp)
+		}
+		o.Expires = &ellipxobj.TimeId{Type: "expire", Unix: uint64(ts.Unix()), Nano: uint32(ts.Nanosecond())}
+	default:
+		return nil, "", fieldError(TagTimeInForce, tif)
+	}
+
+	var orig string
+	if m.Type() == MsgOrderCancelReplaceRequest {
+		orig, _ = m.Get(TagOrigClOrdID)
+		if o.Target, err = getTimeId(m, TagOrderID); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := o.IsValid(); err != nil {
+		return nil, "", err
+	}
+	return o, orig, nil
+}
+
+// DecodeCancel decodes an OrderCancelRequest message
+func DecodeCancel(m *Message) (*CancelRequest, error) {
+	if m.Type() != MsgOrderCancelRequest {
+		return nil, ErrUnsupported
+	}
+	pair, typ, err := decodeInstrument(m)
+	if err != nil {
+		return nil, err
+	}
+	c := &CancelRequest{Pair: pair, Type: typ}
+	c.ClOrdID, _ = m.Get(TagClOrdID)
+	c.OrigClOrdID, _ = m.Get(TagOrigClOrdID)
+	c.BrokerId, _ = m.Get(TagSenderCompID)
+	if c.Target, err = getTimeId(m, TagOrderID); err != nil {
+		return nil, err
+	}
+	if c.OrigClOrdID == "" && c.Target == nil {
+		return nil, fmt.Errorf("%w: %d", ErrFieldMissing, TagOrigClOrdID)
+	}
+	return c, nil
+}
+
+// NewOrderSingle encodes order o as a NewOrderSingle message
+func NewOrderSingle(o *ellipxobj.Order) *Message {
+	m := NewMessage(MsgNewOrderSingle)
+	encodeOrder(m, o)
+	return m
+}
+
+// OrderCancelReplaceRequest encodes order o, replacing the order with ClOrdID
+// orig (and Unique id o.Target, if set), as an OrderCancelReplaceRequest message
+func OrderCancelReplaceRequest(o *ellipxobj.Order, orig string) *Message {
+	m := NewMessage(MsgOrderCancelReplaceRequest)
+	if o.Target != nil {
+		m.Add(TagOrderID, o.Target.String())
+	}
+	m.Add(TagOrigClOrdID, orig)
+	encodeOrder(m, o)
+	return m
+}
+
+// OrderCancelRequest encodes c as an OrderCancelRequest message sent at time now
+func OrderCancelRequest(c *CancelRequest, now time.Time) *Message {
+	m := NewMessage(MsgOrderCancelRequest)
+	m.Add(TagSenderCompID, c.BrokerId)
+	if c.Target != nil {
+		m.Add(TagOrderID, c.Target.String())
+	}
+	m.Add(TagClOrdID, c.ClOrdID)
+	m.Add(TagOrigClOrdID, c.OrigClOrdID)
+	m.Add(TagSymbol, Symbol(c.Pair))
+	m.Add(TagSide, side(c.Type))
+	m.Add(TagTransactTime, now.UTC().Format(TimestampFormat))
+	return m
+}
+
+func encodeOrder(m *Message, o *ellipxobj.Order) {
+	m.Add(TagSenderCompID, o.BrokerId)
+	m.Add(TagAccount, o.UserId)
+	m.Add(TagClOrdID, o.OrderId)
+	m.Add(TagSymbol, Symbol(o.Pair))
+	m.Add(TagSide, side(o.Type))
+	m.Add(TagTransactTime, time.Unix(int64(o.RequestTime), 0).UTC().Format(TimestampFormat))
+	m.Add(TagOrderQty, amountString(o.Amount))
+	m.Add(TagCashOrderQty, amountString(o.SpendLimit))
+	m.Add(TagMaxFloor, amountString(o.Display))
+
+	stop := o.Flags.Has(ellipxobj.FlagStop)
+	switch {
+	case stop && o.Price != nil:
+		m.Add(TagOrdType, "4")
+	case stop:
+		m.Add(TagOrdType, "3")
+	case o.Price != nil:
+		m.Add(TagOrdType, "2")
+	default:
+		m.Add(TagOrdType, "1")
+	}
+	m.Add(TagPrice, amountString(o.Price))
+	if stop {
+		m.Add(TagStopPx, amountString(o.StopPrice))
+	}
+
+	switch {
+	case o.Flags.Has(ellipxobj.FlagFillOrKill):
+		m.Add(TagTimeInForce, "4")
+	case o.Flags.Has(ellipxobj.FlagImmediateOrCancel):
+		m.Add(TagTimeInForce, "3")
+	case o.TimeInForce == ellipxobj.TimeInForceDay:
+		m.Add(TagTimeInForce, "0")
+	case o.TimeInForce == ellipxobj.TimeInForceGTD && o.Expires != nil:
+		m.Add(TagTimeInForce, "6")
+		m.Add(TagExpireTime, o.Expires.Time().UTC().Format(TimestampFormat))
+	default:
+		m.Add(TagTimeInForce, "1")
+	}
+}
+
+func decodeInstrument(m *Message) (ellipxobj.PairName, ellipxobj.OrderType, error) {
+	sym, ok := m.Get(TagSymbol)
+	if !ok {
+		return ellipxobj.PairName{}, 0, fmt.Errorf("%w: %d", ErrFieldMissing, TagSymbol)
+	}
+	pair, err := ParseSymbol(sym)
+	if err != nil {
+		return pair, 0, fieldError(TagSymbol, sym)
+	}
+
+	s, _ := m.Get(TagSide)
+	switch s {
+	case "1":
+		return pair, ellipxobj.TypeBid, nil
+	case "2":
+		return pair, ellipxobj.TypeAsk, nil
+	default:
+		return pair, 0, fieldError(TagSide, s)
+	}
+}
+
+func side(t ellipxobj.OrderType) string {
+	if t == ellipxobj.TypeAsk {
+		return "2"
+	}
+	return "1"
+}
+
+func getAmount(m *Message, tag int) (*ellipxobj.Amount, error) {
+	v, ok := m.Get(tag)
+	if !ok {
+		return nil, nil
+	}
+	a, err := ellipxobj.NewAmountFromString(v, 0)
+	if err != nil || a.Sign() < 0 {
+		return nil, fieldError(tag, v)
+	}
+	return a, nil
+}
+
+func getTimeId(m *Message, tag int) (*ellipxobj.TimeId, error) {
+	v, ok := m.Get(tag)
+	if !ok {
+		return nil, nil
+	}
+	t, err := ellipxobj.ParseTimeId(v)
+	if err != nil {
+		return nil, fieldError(tag, v)
+	}
+	return t, nil
+}
+
+func amountString(a *ellipxobj.Amount) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+func fieldError(tag int, value string) error {
+	return fmt.Errorf("%w: %d=%q", ErrFieldNotValid, tag, value)
+}
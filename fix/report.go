@@ -0,0 +1,273 @@
+package fix
+
+import (
+	"time"
+
+	"github.com/EllipX/ellipxobj"
+)
+
+// ExecType values
+const (
+	ExecNew       = "0"
+	ExecCanceled  = "4"
+	ExecReplaced  = "5"
+	ExecTrade     = "F"
+	ExecTriggered = "L"
+)
+
+// OrdStatus values
+const (
+	StatusNew             = "0"
+	StatusPartiallyFilled = "1"
+	StatusFilled          = "2"
+	StatusCanceled        = "4"
+)
+
+// execState is what is known about the executions of an order
+type execState struct {
+	id     *ellipxobj.TimeId // OrderID of reports, the first Unique id of the order
+	unique *ellipxobj.TimeId // current Unique id of the order in the book
+	leaves *ellipxobj.Amount // remaining quantity, nil if unknown (spend limit orders)
+	cum    *ellipxobj.Amount // executed quantity
+	spent  *ellipxobj.Amount // executed value, to compute the average price
+}
+
+type orderKey struct {
+	broker, order string
+}
+
+// Reporter converts the events of an OrderBook into ExecutionReports sent to the
+// brokers owning the orders. It keeps the executed quantity of each order, which
+// is needed by reports but not held by orders. Orders already resting in the book
+// when the Reporter is created should be registered with Track.
+//
+// The OrderID of reports is the Unique id of the order when it was first reported,
+// which clients can send back in cancel and replace requests. It stays the same
+// when an amendment makes the order lose its priority and the book gives it a new
+// Unique id, so requests must be resolved with Target.
+type Reporter struct {
+	SenderCompID string          // Sender of reports, the TargetCompID being the order's BrokerId
+	Clock        ellipxobj.Clock // Source of TransactTime for events without id (system time if nil)
+
+	orders map[orderKey]*execState
+	ids    map[ellipxobj.TimeId]*execState // orders by OrderID
+}
+
+// NewReporter returns a new Reporter
+func NewReporter(sender string) *Reporter {
+	return &Reporter{
+		SenderCompID: sender,
+		orders:       make(map[orderKey]*execState),
+		ids:          make(map[ellipxobj.TimeId]*execState),
+	}
+}
+
+// Track registers an order that was accepted before the reporter was created
+func (r *Reporter) Track(o *ellipxobj.Order) {
+	r.state(o.BrokerId, o.OrderId, o)
+}
+
+// Target returns the current Unique id in the book of the order reported with
+// OrderID id, to be used as Target of cancel and replace requests. id is returned
+// as is if the order is unknown.
+func (r *Reporter) Target(id *ellipxobj.TimeId) *ellipxobj.TimeId {
+	if id == nil {
+		return nil
+	}
+	if s, ok := r.ids[*id]; ok && s.unique != nil {
+		res := *s.unique
+		return &res
+	}
+	return id
+}
+
+// Reports returns the execution reports for the given events, as returned by a
+// single OrderBook operation. Events not related to an order (such as halts) do
+// not cause reports, and trades cause one report for each side.
+func (r *Reporter) Reports(events []*ellipxobj.Event) []*Message {
+	var res []*Message
+	for n, ev := range events {
+		switch ev.Type {
+		case ellipxobj.EventAccept:
+			res = append(res, r.report(ev, ev.Order, ExecNew, ""))
+		case ellipxobj.EventCancel:
+			res = append(res, r.report(ev, ev.Order, ExecCanceled, StatusCanceled))
+			r.forget(ev.Order)
+		case ellipxobj.EventAmend:
+			res = append(res, r.report(ev, ev.Order, ExecReplaced, ""))
+		case ellipxobj.EventTrigger:
+			res = append(res, r.report(ev, ev.Order, ExecTriggered, ""))
+		case ellipxobj.EventTrade:
+			for _, meta := range []*ellipxobj.OrderMeta{ev.Trade.Bid, ev.Trade.Ask} {
+				res = append(res, r.tradeReport(ev, meta, doneAfter(events[n+1:], meta)))
+			}
+		case ellipxobj.EventDone:
+			r.forget(ev.Order)
+		}
+	}
+	return res
+}
+
+// state returns the execution state of an order, creating it from o if needed
+func (r *Reporter) state(broker, order string, o *ellipxobj.Order) *execState {
+	k := orderKey{broker, order}
+	s, ok := r.orders[k]
+	if !ok {
+		s = &execState{}
+		if o != nil && o.Amount != nil {
+			s.leaves = o.Amount.Dup()
+		}
+		r.orders[k] = s
+	}
+	if o != nil {
+		r.move(s, o.Unique)
+	}
+	return s
+}
+
+// move records unique as the current Unique id of the order of s. The first id
+// recorded is the OrderID of its reports.
+func (r *Reporter) move(s *execState, unique *ellipxobj.TimeId) {
+	if unique == nil {
+		return
+	}
+	t := *unique
+	s.unique = &t
+	if s.id == nil {
+		s.id = s.unique
+		r.ids[t] = s
+	}
+}
+
+func (r *Reporter) forget(o *ellipxobj.Order) {
+	k := orderKey{o.BrokerId, o.OrderId}
+	if s, ok := r.orders[k]; ok && s.id != nil {
+		delete(r.ids, *s.id)
+	}
+	delete(r.orders, k)
+}
+
+// report returns a report about order o. If status is empty it is computed from
+// the executed quantity.
+func (r *Reporter) report(ev *ellipxobj.Event, o *ellipxobj.Order, execType, status string) *Message {
+	s := r.state(o.BrokerId, o.OrderId, o)
+	if execType == ExecReplaced && o.Amount != nil {
+		s.leaves = o.Amount.Dup()
+	}
+	if status == "" {
+		status = StatusNew
+		if s.cum != nil && s.cum.Sign() > 0 {
+			status = StatusPartiallyFilled
+		}
+	}
+	leaves := s.leaves
+	if status == StatusCanceled {
+		leaves = nil
+	}
+
+	m := r.header(ev, o.BrokerId, s.id, o.OrderId, execType, status)
+	m.Add(TagSymbol, Symbol(o.Pair))
+	m.Add(TagSide, side(o.Type))
+	m.Add(TagOrderQty, amountString(o.Amount))
+	m.Add(TagPrice, amountString(o.Price))
+	r.quantities(m, s, leaves)
+	m.Add(TagText, ev.Reason)
+	return m
+}
+
+// tradeReport returns the report of a trade for the order described by meta
+func (r *Reporter) tradeReport(ev *ellipxobj.Event, meta *ellipxobj.OrderMeta, done bool) *Message {
+	t := ev.Trade
+	s := r.state(meta.BrokerId, meta.OrderId, nil)
+	r.move(s, meta.Unique)
+	spent := t.Spent()
+	if s.cum == nil {
+		s.cum, s.spent = t.Amount.Dup(), spent
+	} else {
+		s.cum = ellipxobj.NewAmount(0, s.cum.Exp()).Add(s.cum, t.Amount)
+		s.spent = ellipxobj.NewAmount(0, s.spent.Exp()).Add(s.spent, spent)
+	}
+	if s.leaves != nil {
+		s.leaves = ellipxobj.NewAmount(0, s.leaves.Exp()).Sub(s.leaves, t.Amount)
+	}
+
+	status := StatusPartiallyFilled
+	if done {
+		status = StatusFilled
+	}
+	typ := ellipxobj.TypeBid
+	if meta == t.Ask {
+		typ = ellipxobj.TypeAsk
+	}
+
+	m := r.header(ev, meta.BrokerId, s.id, meta.OrderId, ExecTrade, status)
+	m.Add(TagSymbol, Symbol(t.Pair))
+	m.Add(TagSide, side(typ))
+	m.Add(TagLastQty, t.Amount.String())
+	m.Add(TagLastPx, t.Price.String())
+	r.quantities(m, s, s.leaves)
+	return m
+}
+
+func (r *Reporter) header(ev *ellipxobj.Event, broker string, unique *ellipxobj.TimeId, clOrdId, execType, status string) *Message {
+	m := NewMessage(MsgExecutionReport)
+	m.Add(TagSenderCompID, r.SenderCompID)
+	m.Add(TagTargetCompID, broker)
+	if unique != nil {
+		m.Add(TagOrderID, unique.String())
+	}
+	m.Add(TagClOrdID, clOrdId)
+	if ev.Id != nil {
+		m.Add(TagExecID, ev.Id.String())
+		m.Add(TagTransactTime, ev.Id.Time().UTC().Format(TimestampFormat))
+	} else {
+		m.Add(TagTransactTime, r.now().UTC().Format(TimestampFormat))
+	}
+	m.Add(TagExecType, execType)
+	m.Add(TagOrdStatus, status)
+	return m
+}
+
+func (r *Reporter) now() time.Time {
+	if r.Clock == nil {
+		return time.Now()
+	}
+	return r.Clock.Now()
+}
+
+// quantities adds LeavesQty, CumQty and AvgPx to m
+func (r *Reporter) quantities(m *Message, s *execState, leaves *ellipxobj.Amount) {
+	if leaves == nil || leaves.Sign() < 0 {
+		m.Add(TagLeavesQty, "0")
+	} else {
+		m.Add(TagLeavesQty, leaves.String())
+	}
+	if s.cum == nil || s.cum.Sign() == 0 {
+		m.Add(TagCumQty, "0")
+		m.Add(TagAvgPx, "0")
+		return
+	}
+	m.Add(TagCumQty, s.cum.String())
+	m.Add(TagAvgPx, ellipxobj.NewAmount(0, s.spent.Exp()).Div(s.spent, s.cum).String())
+}
+
+// doneAfter returns true if the next event about the order described by meta is
+// EventDone
+func doneAfter(events []*ellipxobj.Event, meta *ellipxobj.OrderMeta) bool {
+	for _, ev := range events {
+		if ev.Type == ellipxobj.EventTrade {
+			if sameOrder(ev.Trade.Bid, meta) || sameOrder(ev.Trade.Ask, meta) {
+				return false
+			}
+			continue
+		}
+		if ev.Order != nil && sameOrder(ev.Order.Meta(), meta) {
+			return ev.Type == ellipxobj.EventDone
+		}
+	}
+	return false
+}
+
+func sameOrder(a, b *ellipxobj.OrderMeta) bool {
+	return a.BrokerId == b.BrokerId && a.OrderId == b.OrderId
+}
@@ -0,0 +1,264 @@
+package ellipxobj
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// GroupType defines how orders of an OrderGroup relate to each other
+type GroupType int
+
+const (
+	GroupInvalid GroupType = -1
+	GroupOCO     GroupType = iota // one-cancels-other: any activity on one order cancels the others
+	GroupBracket                  // entry order, with take-profit and stop-loss orders activated on entry fill
+)
+
+func (t GroupType) String() string {
+	switch t {
+	case GroupOCO:
+		return "oco"
+	case GroupBracket:
+		return "bracket"
+	default:
+		return "invalid"
+	}
+}
+
+func (t GroupType) IsValid() bool {
+	switch t {
+	case GroupOCO, GroupBracket:
+		return true
+	default:
+		return false
+	}
+}
+
+func GroupTypeByString(s string) GroupType {
+	switch s {
+	case "oco":
+		return GroupOCO
+	case "bracket":
+		return GroupBracket
+	default:
+		return GroupInvalid
+	}
+}
+
+func (t GroupType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *GroupType) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	v := GroupTypeByString(s)
+	if v == GroupInvalid {
+		return fmt.Errorf("invalid group type %q", s)
+	}
+	*t = v
+	return nil
+}
+
+// OrderGroup links orders that need to be handled together by the book.
+//
+// For GroupOCO, filling (even partially), triggering or cancelling any order of the
+// group cancels all the others.
+//
+// For GroupBracket, Orders must contain exactly 3 orders: the entry order, the
+// take-profit order (a limit order) and the stop-loss order (with FlagStop), the
+// last two being of the opposite type of the entry. Only the entry is placed at
+// first. Once it is done (or cancelled after being partially filled), the two
+// other orders are placed with their Amount limited to the filled quantity, and
+// behave as an OCO group. If the entry is cancelled without any fill, the group is
+// cancelled.
+type OrderGroup struct {
+	Id     string    `json:"id"`
+	Type   GroupType `json:"type"`
+	Orders []*Order  `json:"orders"`
+
+	// State of bracket groups, maintained by the book
+	Filled *Amount `json:"filled,omitempty"` // quantity filled on the entry order
+	Active bool    `json:"active,omitempty"` // take-profit and stop-loss have been activated
+}
+
+// dup returns a copy of the group and its orders
+func (g *OrderGroup) dup() *OrderGroup {
+	res := &OrderGroup{Id: g.Id, Type: g.Type, Filled: g.Filled.Dup(), Active: g.Active}
+	for _, o := range g.Orders {
+		res.Orders = append(res.Orders, o.Dup())
+	}
+	return res
+}
+
+// IsValid checks the group is consistent. Orders themselves are checked by the
+// book when the group is executed.
+func (g *OrderGroup) IsValid() error {
+	if g.Id == "" {
+		return ErrGroupIdMissing
+	}
+	if !g.Type.IsValid() {
+		return ErrGroupNotValid
+	}
+	if len(g.Orders) < 2 {
+		return ErrGroupNotValid
+	}
+
+	first := g.Orders[0]
+	for _, o := range g.Orders {
+		if o.Pair != first.Pair || o.BrokerId != first.BrokerId || o.UserId != first.UserId {
+			return ErrGroupNotValid
+		}
+		if o.Group != "" && o.Group != g.Id {
+			return ErrGroupNotValid
+		}
+	}
+
+	if g.Type == GroupBracket {
+		if len(g.Orders) != 3 {
+			return ErrGroupNotValid
+		}
+		tp, sl := g.Orders[1], g.Orders[2]
+		if tp.Type != first.Type.Reverse() || sl.Type != first.Type.Reverse() {
+			return ErrGroupNotValid
+		}
+		if tp.Price == nil || tp.Flags.Has(FlagStop) || !sl.Flags.Has(FlagStop) {
+			return ErrGroupNotValid
+		}
+		if tp.Amount == nil || sl.Amount == nil {
+			return ErrOrderNeedsAmount
+		}
+	}
+	return nil
+}
+
+// ExecuteGroup processes the orders of group g (see OrderGroup). Either all orders
+// of the group are accepted, or an error is returned and none is.
+func (b *OrderBook) ExecuteGroup(g *OrderGroup) ([]*Event, error) {
+	if err := g.IsValid(); err != nil {
+		return nil, err
+	}
+	if _, found := b.groups[g.Id]; found {
+		return nil, ErrGroupExists
+	}
+	for _, o := range g.Orders {
+		if err := b.check(o); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.groups == nil {
+		b.groups = make(map[string]*OrderGroup)
+	}
+	b.groups[g.Id] = g
+	for _, o := range g.Orders {
+		o.Group = g.Id
+		o.Status = OrderPending
+	}
+
+	switch g.Type {
+	case GroupOCO:
+		for _, o := range g.Orders {
+			if o.Status == OrderCancel {
+				// a previous order of the group traded and already cancelled the others
+				continue
+			}
+			b.place(o)
+		}
+	case GroupBracket:
+		g.Filled = NewAmount(0, b.Market.AmountExp)
+		g.Active = false
+		b.place(g.Orders[0])
+	}
+	b.settle()
+
+	return b.flush(), nil
+}
+
+// groupOf returns the active group of order o, if any
+func (b *OrderBook) groupOf(o *Order) *OrderGroup {
+	if o.Group == "" {
+		return nil
+	}
+	return b.groups[o.Group]
+}
+
+// groupTrade is called when order o was part of trade t
+func (b *OrderBook) groupTrade(o *Order, t *Trade) {
+	g := b.groupOf(o)
+	if g == nil {
+		return
+	}
+	if g.Type == GroupBracket && o == g.Orders[0] {
+		g.Filled = g.Filled.Add(g.Filled, t.Amount)
+		return
+	}
+	b.groupActivity(o)
+}
+
+// groupActivity is called when order o traded, was triggered or was cancelled,
+// and cancels the other orders of its group where needed
+func (b *OrderBook) groupActivity(o *Order) {
+	g := b.groupOf(o)
+	if g == nil {
+		return
+	}
+	switch g.Type {
+	case GroupOCO:
+		b.groupResolve(g, o, "oco")
+	case GroupBracket:
+		if g.Active && o != g.Orders[0] {
+			b.groupResolve(g, o, "oco")
+		}
+	}
+}
+
+// groupClosed is called when order o reached a final status
+func (b *OrderBook) groupClosed(o *Order) {
+	g := b.groupOf(o)
+	if g == nil {
+		return
+	}
+	if g.Type != GroupBracket || o != g.Orders[0] {
+		b.groupActivity(o)
+		return
+	}
+
+	// the entry order of a bracket group was closed
+	if g.Filled.Sign() <= 0 {
+		b.groupResolve(g, o, "bracket")
+		return
+	}
+	g.Active = true
+	for _, c := range g.Orders[1:] {
+		if c.Amount.Cmp(g.Filled) > 0 {
+			c.Amount = g.Filled.Dup()
+		}
+		b.queue = append(b.queue, c)
+	}
+}
+
+// groupResolve ends group g, cancelling all orders except o that are not final yet
+func (b *OrderBook) groupResolve(g *OrderGroup, o *Order, reason string) {
+	delete(b.groups, g.Id)
+
+	for _, m := range g.Orders {
+		if m == o {
+			continue
+		}
+		switch m.Status {
+		case OrderDone, OrderCancel:
+			continue
+		case OrderPending:
+			if m.Unique == nil {
+				b.allocate(m, nil)
+			}
+		default:
+			b.remove(m)
+		}
+		b.cancel(m, reason)
+	}
+}
@@ -0,0 +1,135 @@
+package ellipxobj
+
+import "math/big"
+
+// impliedExp is the precision used for intermediate computations of implied books
+const impliedExp = 18
+
+// Reverse returns the depth of the reversed pair, the same way Order.Reverse does
+// for orders: bids become asks and the other way around, prices are inverted and
+// amounts are expressed in the other asset. Values are truncated to amountExp and
+// priceExp.
+func (d *Depth) Reverse(amountExp, priceExp int) *Depth {
+	return &Depth{
+		Pair: PairName{d.Pair[1], d.Pair[0]},
+		Bids: reverseLevels(d.Asks, amountExp, priceExp),
+		Asks: reverseLevels(d.Bids, amountExp, priceExp),
+	}
+}
+
+func reverseLevels(levels []*DepthLevel, amountExp, priceExp int) []*DepthLevel {
+	res := make([]*DepthLevel, 0, len(levels))
+	for _, l := range levels {
+		if l.Price.Sign() <= 0 {
+			continue
+		}
+		res = append(res, &DepthLevel{
+			Price:  NewAmount(0, priceExp).Div(NewAmount(1, 0), l.Price),
+			Amount: NewAmount(0, amountExp).Mul(l.Amount, l.Price),
+			Count:  l.Count,
+		})
+	}
+	return res
+}
+
+// ImpliedDepth returns the implied depth of market m derived from two legs sharing
+// a common asset, for example ETH_BTC from ETH_USD and BTC_USD. Leg a must trade
+// the base asset of m and leg b its quote asset, each in either direction.
+//
+// An implied bid sells the base asset on leg a and buys the quote asset on leg b,
+// and the available quantity of each level is constrained by both legs. Up to levels
+// price levels are returned on each side (all if levels is zero or negative). Prices
+// are rounded against the taker and amounts are truncated, so the implied depth
+// never shows more liquidity than is actually available.
+func ImpliedDepth(m *Market, a, b *Depth, levels int) (*Depth, error) {
+	a, err := orientDepth(a, m.Pair[0])
+	if err != nil {
+		return nil, err
+	}
+	b, err = orientDepth(b, m.Pair[1])
+	if err != nil {
+		return nil, err
+	}
+	if a.Pair[1] != b.Pair[1] {
+		return nil, ErrPairMismatch
+	}
+
+	return &Depth{
+		Pair: m.Pair,
+		Bids: impliedLevels(m, a.Bids, b.Asks, false, levels),
+		Asks: impliedLevels(m, a.Asks, b.Bids, true, levels),
+	}, nil
+}
+
+// orientDepth returns d with asset as its base, reversing it if needed
+func orientDepth(d *Depth, asset string) (*Depth, error) {
+	switch asset {
+	case d.Pair[0]:
+		return d, nil
+	case d.Pair[1]:
+		return d.Reverse(impliedExp, impliedExp), nil
+	default:
+		return nil, ErrPairMismatch
+	}
+}
+
+// impliedLevels combines levels of both legs, each expressed in the common asset,
+// walking them from the best price. The price of each implied level is the ratio of
+// the prices of both legs, and its amount is the smallest value available on either
+// leg.
+func impliedLevels(m *Market, la, lb []*DepthLevel, up bool, levels int) []*DepthLevel {
+	res := []*DepthLevel{}
+	var cur *DepthLevel
+	var va, vb *Amount // remaining value of current levels, in the common asset
+
+	for i, j := 0, 0; i < len(la) && j < len(lb); {
+		if va == nil {
+			va = NewAmount(0, impliedExp).Mul(la[i].Amount, la[i].Price)
+		}
+		if vb == nil {
+			vb = NewAmount(0, impliedExp).Mul(lb[j].Amount, lb[j].Price)
+		}
+
+		v := va
+		if vb.Cmp(va) < 0 {
+			v = vb
+		}
+		price := truncExp(NewAmount(0, impliedExp).Div(la[i].Price, lb[j].Price), m.PriceExp, up)
+		amount := truncExp(NewAmount(0, impliedExp).Div(v, la[i].Price), m.AmountExp, false)
+
+		if amount.Sign() > 0 && lb[j].Price.Sign() > 0 {
+			if cur == nil || cur.Price.Cmp(price) != 0 {
+				if levels > 0 && len(res) == levels {
+					break
+				}
+				cur = &DepthLevel{Price: price, Amount: NewAmount(0, m.AmountExp)}
+				res = append(res, cur)
+			}
+			cur.Amount = cur.Amount.Add(cur.Amount, amount)
+			cur.Count += 1
+		}
+
+		va = NewAmount(0, impliedExp).Sub(va, v)
+		vb = NewAmount(0, impliedExp).Sub(vb, v)
+		if va.Sign() <= 0 {
+			i, va = i+1, nil
+		}
+		if vb.Sign() <= 0 {
+			j, vb = j+1, nil
+		}
+	}
+	return res
+}
+
+// truncExp returns a with exp decimals, truncated or rounded up (away from zero)
+func truncExp(a *Amount, exp int, up bool) *Amount {
+	if exp >= a.exp {
+		return a.Dup().SetExp(exp)
+	}
+	e10 := exp10(a.exp - exp)
+	v, rem := new(big.Int).QuoRem(a.value, e10, new(big.Int))
+	if up && rem.Sign() > 0 {
+		v = v.Add(v, big.NewInt(1))
+	}
+	return NewAmountRaw(v, exp)
+}
@@ -0,0 +1,44 @@
+package ellipxobj
+
+import (
+	"testing"
+)
+
+func TestImpliedDepth(t *testing.T) {
+	rest := func(b *OrderBook, typ OrderType, amount, price string) {
+		o := NewOrder(b.Market.Pair, typ).SetId("r", "test")
+		o.Amount = must(NewAmountFromString(amount, b.Market.AmountExp))
+		o.Price = must(NewAmountFromString(price, b.Market.PriceExp))
+		must(b.Execute(o))
+	}
+
+	btc := NewOrderBook(testMarket())
+	eth := NewOrderBook(&Market{Pair: Pair("USD", "ETH"), AmountExp: 5, PriceExp: 8})
+	rest(btc, TypeBid, "0.1", "50000")
+	rest(btc, TypeBid, "1", "49000")
+	rest(btc, TypeAsk, "1", "51000")
+	// selling 20000 USD at 0.0005 ETH is buying 10 ETH at 2000 USD
+	rest(eth, TypeAsk, "20000", "0.0005")
+	// buying 40000 USD at 0.00025 ETH is selling 10 ETH at 4000 USD
+	rest(eth, TypeBid, "40000", "0.00025")
+
+	m := &Market{Pair: Pair("ETH", "BTC"), AmountExp: 8, PriceExp: 8}
+	d := must(NewRouter(btc, eth).Implied(m, "USD", 0))
+
+	// sell ETH at 2000, buy BTC at 51000: 2000/51000 rounded down, limited by ETH
+	if len(d.Bids) != 1 || d.Bids[0].Price.String() != "0.03921568" || d.Bids[0].Amount.String() != "10.00000000" {
+		t.Errorf("unexpected implied bids %+v", d.Bids)
+	}
+	// buy ETH at 4000, sell 0.1 BTC at 50000 (5000 USD) then 1 BTC at 49000
+	if len(d.Asks) != 2 || d.Asks[0].Price.String() != "0.08000000" || d.Asks[0].Amount.String() != "1.25000000" {
+		t.Fatalf("unexpected implied asks %+v", d.Asks)
+	}
+	// 4000/49000 rounded up, limited by the remaining 35000 USD of ETH
+	if d.Asks[1].Price.String() != "0.08163266" || d.Asks[1].Amount.String() != "8.75000000" {
+		t.Errorf("unexpected implied ask %+v", d.Asks[1])
+	}
+
+	if _, err := NewRouter(btc).Implied(m, "USD", 0); err != ErrNoRoute {
+		t.Errorf("expected no route, got %v", err)
+	}
+}
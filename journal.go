@@ -0,0 +1,268 @@
+package ellipxobj
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// Journal records are framed as a 4 bytes length, a 4 bytes CRC-32C checksum of
+// the payload and the payload itself (the JSON encoding of a JournalRecord), with
+// integers in big endian.
+const (
+	journalHeaderLen = 8
+	journalMaxRecord = 16 << 20
+)
+
+var journalTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Journal is an append-only file of the inputs and outputs of an OrderBook: each
+// Command applied to the book (see OrderBook.Apply) followed by the events it
+// generated, written with WriteCommand before the result is acted upon.
+//
+// Combined with a Checkpoint, this allows recovering a book after a crash by
+// loading the checkpoint and applying the commands of the journal recorded after
+// Checkpoint.Point. Since the ids generated by a command only depend on its Id
+// (see OrderBook.Apply), the rebuilt book produces exactly the recorded events.
+// Events alone are not enough to rebuild a book, since they do not carry the
+// parameters of all operations (order groups, expiry time, halts and auctions).
+type Journal struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+// JournalRecord is a record of a Journal: either a command or an event
+type JournalRecord struct {
+	Command *Command `json:"command,omitempty"`
+	Event   *Event   `json:"event,omitempty"`
+}
+
+// Id returns the id of the command or event of the record
+func (r *JournalRecord) Id() *TimeId {
+	if r.Command != nil {
+		return r.Command.Id
+	}
+	if r.Event != nil {
+		return r.Event.Id
+	}
+	return nil
+}
+
+// OpenJournal opens or creates the journal at path for appending. If an existing
+// journal ends with a record that was not fully written (typically because of a
+// crash while writing), it is truncated after the last valid record. A journal
+// with an invalid record followed by other data is corrupted, and causes
+// ErrJournalCorrupt.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// find the end of the last complete record
+	r := NewJournalReader(f)
+	for {
+		_, err = r.Next()
+		if err != nil {
+			break
+		}
+	}
+	if err != io.EOF {
+		torn, terr := isTornTail(f, r.Offset(), err)
+		if terr != nil {
+			f.Close()
+			return nil, terr
+		}
+		if !torn {
+			f.Close()
+			return nil, err
+		}
+		if err = f.Truncate(r.Offset()); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	if _, err = f.Seek(r.Offset(), io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Journal{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// isTornTail returns true if err, returned when reading the record at offset off
+// of f, is caused by a partial write at the end of the journal rather than by a
+// corrupted record: the record is incomplete, reaches the end of the file, or is
+// followed only by zeros (space allocated by the filesystem but never written).
+func isTornTail(f *os.File, off int64, err error) (bool, error) {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true, nil
+	}
+	if err != ErrJournalCorrupt {
+		return false, nil
+	}
+
+	st, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	var hdr [journalHeaderLen]byte
+	if _, err := f.ReadAt(hdr[:], off); err != nil {
+		return false, err
+	}
+	if off+journalHeaderLen+int64(binary.BigEndian.Uint32(hdr[:4])) >= st.Size() {
+		return true, nil
+	}
+
+	buf := make([]byte, 32*1024)
+	for off < st.Size() {
+		n, err := f.ReadAt(buf, off)
+		for _, c := range buf[:n] {
+			if c != 0 {
+				return false, nil
+			}
+		}
+		off += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// WriteCommand appends command c, which must have been applied already so its Id
+// is set, and the events it generated to the journal. It only returns once they
+// have been synced to disk.
+func (j *Journal) WriteCommand(c *Command, events ...*Event) error {
+	if err := j.append(&JournalRecord{Command: c}); err != nil {
+		return err
+	}
+	return j.Write(events...)
+}
+
+// Write appends events to the journal, and only returns once they have been
+// synced to disk.
+func (j *Journal) Write(events ...*Event) error {
+	for _, ev := range events {
+		if err := j.append(&JournalRecord{Event: ev}); err != nil {
+			return err
+		}
+	}
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+// append writes record rec to the buffer of the journal
+func (j *Journal) append(rec *JournalRecord) error {
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	var hdr [journalHeaderLen]byte
+	binary.BigEndian.PutUint32(hdr[:4], uint32(len(buf)))
+	binary.BigEndian.PutUint32(hdr[4:], crc32.Checksum(buf, journalTable))
+	j.w.Write(hdr[:])
+	j.w.Write(buf)
+	return nil
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	if err := j.w.Flush(); err != nil {
+		j.f.Close()
+		return err
+	}
+	return j.f.Close()
+}
+
+// JournalReader iterates over the records of a journal
+type JournalReader struct {
+	r   *bufio.Reader
+	off int64
+}
+
+// NewJournalReader returns a reader reading journal records from r
+func NewJournalReader(r io.Reader) *JournalReader {
+	return &JournalReader{r: bufio.NewReader(r)}
+}
+
+// Offset returns the offset of the end of the last record read successfully
+func (r *JournalReader) Offset() int64 {
+	return r.off
+}
+
+// Next returns the next record of the journal, or io.EOF at the end of the
+// journal. If the journal ends in the middle of a record, io.ErrUnexpectedEOF is
+// returned, and ErrJournalCorrupt if a record is not valid.
+func (r *JournalReader) Next() (*JournalRecord, error) {
+	var hdr [journalHeaderLen]byte
+	if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+		return nil, err
+	}
+	ln := binary.BigEndian.Uint32(hdr[:4])
+	if ln > journalMaxRecord {
+		return nil, ErrJournalCorrupt
+	}
+
+	buf := make([]byte, ln)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if crc32.Checksum(buf, journalTable) != binary.BigEndian.Uint32(hdr[4:]) {
+		return nil, ErrJournalCorrupt
+	}
+
+	rec := &JournalRecord{}
+	if err := json.Unmarshal(buf, rec); err != nil || (rec.Command == nil) == (rec.Event == nil) {
+		return nil, ErrJournalCorrupt
+	}
+	r.off += journalHeaderLen + int64(ln)
+	return rec, nil
+}
+
+// ReadJournal calls fn for each record of the journal at path recorded after point,
+// typically the Point of the last Checkpoint. A last record that was not fully
+// written is ignored (see OpenJournal).
+func ReadJournal(path string, point TimeId, fn func(*JournalRecord) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := NewJournalReader(f)
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			torn, terr := isTornTail(f, r.Offset(), err)
+			switch {
+			case terr != nil:
+				return terr
+			case !torn:
+				return err
+			}
+			return nil
+		}
+		if id := rec.Id(); id != nil && id.Cmp(point) <= 0 {
+			continue
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
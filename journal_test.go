@@ -0,0 +1,155 @@
+package ellipxobj
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.journal")
+	j := must(OpenJournal(path))
+
+	b := NewOrderBook(testMarket())
+	apply := func(c *Command) *Command {
+		if err := j.WriteCommand(c, must(b.Apply(c))...); err != nil {
+			t.Fatalf("failed to write journal: %s", err)
+		}
+		return c
+	}
+	apply(&Command{Type: CommandExecute, Order: testOrder("a1", "alice", TypeAsk, "1", "100")})
+	point := b.ids.Last
+	apply(&Command{Type: CommandExecute, Order: testOrder("b1", "bob", TypeBid, "0.4", "100")})
+	j.Close()
+
+	// simulate a crash in the middle of writing a record
+	f := must(os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0))
+	f.Write([]byte{0, 0, 1, 0, 1, 2})
+	f.Close()
+
+	j = must(OpenJournal(path))
+	apply(&Command{Type: CommandCancel, Target: b.Asks[0].Unique})
+	j.Close()
+
+	var all []*JournalRecord
+	if err := ReadJournal(path, TimeId{}, func(rec *JournalRecord) error { all = append(all, rec); return nil }); err != nil {
+		t.Fatalf("failed to read journal: %s", err)
+	}
+	if len(all) != 9 || all[0].Command.Type != CommandExecute || all[0].Command.Order.Unique == nil || all[5].Event.Type != EventTrade || all[5].Event.Trade.Amount.String() != "0.40000000" || all[7].Command.Type != CommandCancel || all[8].Event.Type != EventCancel {
+		t.Errorf("unexpected journal records %v", all)
+	}
+
+	// replay after the first order was opened
+	var replay []*JournalRecord
+	ReadJournal(path, point, func(rec *JournalRecord) error { replay = append(replay, rec); return nil })
+	if len(replay) != 6 || replay[0].Command == nil || replay[0].Command.Order.OrderId != "b1" {
+		t.Errorf("unexpected replayed records %v", replay)
+	}
+
+	// a crash can also leave zeros after the last record
+	f = must(os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0))
+	f.Write(make([]byte, 64))
+	f.Close()
+	j = must(OpenJournal(path))
+	apply(&Command{Type: CommandExecute, Order: testOrder("b2", "bob", TypeBid, "1", "90")})
+	j.Close()
+	all = nil
+	if err := ReadJournal(path, TimeId{}, func(rec *JournalRecord) error { all = append(all, rec); return nil }); err != nil {
+		t.Fatalf("failed to read journal after zero-filled tail: %s", err)
+	}
+	if len(all) != 12 || all[9].Command == nil || all[10].Event.Type != EventAccept || all[11].Event.Type != EventOpen {
+		t.Errorf("unexpected journal records after zero-filled tail %v", all)
+	}
+
+	// corrupt the payload of a record
+	data := must(os.ReadFile(path))
+	data[20] ^= 0xff
+	os.WriteFile(path, data, 0644)
+	if _, err := OpenJournal(path); err != ErrJournalCorrupt {
+		t.Errorf("expected corrupt journal, got %v", err)
+	}
+}
+
+func TestJournalRebuild(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.journal")
+	j := must(OpenJournal(path))
+
+	m := testMarket()
+	b := NewOrderBook(m)
+	apply := func(c *Command) {
+		if err := j.WriteCommand(c, must(b.Apply(c))...); err != nil {
+			t.Fatalf("failed to write journal: %s", err)
+		}
+	}
+
+	day := testOrder("a1", "alice", TypeAsk, "1", "105")
+	now := NewTimeId()
+	day.Expires = &TimeId{Unix: now.Unix + 3600}
+	apply(&Command{Type: CommandExecute, Order: day})
+	apply(&Command{Type: CommandExecute, Order: testOrder("a2", "alice", TypeAsk, "2", "110")})
+	apply(&Command{Type: CommandExecuteGroup, Group: &OrderGroup{Id: "g1", Type: GroupOCO, Orders: []*Order{
+		testOrder("b1", "bob", TypeBid, "1", "95"),
+		testStop(testOrder("b2", "bob", TypeBid, "1", ""), "120"),
+	}}})
+	amend := testOrder("a2", "alice", TypeAsk, "2", "108")
+	amend.Target = b.Asks[1].Unique
+	apply(&Command{Type: CommandAmend, Order: amend})
+	apply(&Command{Type: CommandExpire, Time: &TimeId{Unix: now.Unix + 7200}})
+	apply(&Command{Type: CommandHalt, Reason: "manual"})
+	apply(&Command{Type: CommandExecute, Order: testOrder("c1", "carol", TypeBid, "1", "108")})
+	apply(&Command{Type: CommandResume})
+	apply(&Command{Type: CommandAuctionStart})
+	apply(&Command{Type: CommandExecute, Order: testOrder("c2", "carol", TypeAsk, "0.5", "95")})
+	apply(&Command{Type: CommandAuctionEnd})
+
+	// iceberg replenish and bracket activation generate ids in the book
+	ice := testOrder("d1", "dave", TypeAsk, "3", "100")
+	ice.Display = must(NewAmountFromString("1", 8))
+	apply(&Command{Type: CommandExecute, Order: ice})
+	apply(&Command{Type: CommandExecute, Order: testOrder("e1", "erin", TypeBid, "1", "100")})
+	apply(&Command{Type: CommandExecuteGroup, Group: &OrderGroup{Id: "g2", Type: GroupBracket, Orders: []*Order{
+		testOrder("f1", "frank", TypeBid, "0.5", "100"),
+		testOrder("f2", "frank", TypeAsk, "0.5", "130"),
+		testStop(testOrder("f3", "frank", TypeAsk, "0.5", ""), "90"),
+	}}})
+	j.Close()
+
+	if _, err := b.Apply(&Command{Type: CommandCancel}); err != ErrCommandNotValid {
+		t.Errorf("expected ErrCommandNotValid, got %v", err)
+	}
+
+	// rebuild the book by applying the commands of the journal, at another time
+	r := NewOrderBook(m)
+	r.Clock = NewManualClock(time.Unix(1, 0))
+	var events, replayed []*Event
+	err := ReadJournal(path, TimeId{}, func(rec *JournalRecord) error {
+		if rec.Event != nil {
+			events = append(events, rec.Event)
+			return nil
+		}
+		res, err := r.Apply(rec.Command)
+		replayed = append(replayed, res...)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to rebuild book: %s", err)
+	}
+
+	if len(events) != len(replayed) {
+		t.Fatalf("expected %d events, got %d", len(events), len(replayed))
+	}
+	for n := range events {
+		if !bytes.Equal(must(json.Marshal(events[n])), must(json.Marshal(replayed[n]))) {
+			t.Errorf("event %d differs: %s / %s", n, events[n], replayed[n])
+		}
+	}
+	if !bytes.Equal(r.Checkpoint().Sum(), b.Checkpoint().Sum()) || r.Halted != b.Halted || r.Auction != b.Auction {
+		t.Errorf("rebuilt book differs")
+	}
+	if len(r.Bids) != 1 || len(r.Asks) != 3 || len(r.Stops) != 1 || r.Bids[0].Amount.String() != "0.50000000" || r.Asks[0].Priority == nil {
+		t.Errorf("unexpected rebuilt book")
+	}
+}
@@ -0,0 +1,109 @@
+package ellipxobj
+
+import "math/big"
+
+// Market holds the configuration of a trading pair, as used by an OrderBook.
+type Market struct {
+	Pair      PairName      `json:"pair"`              // Trading pair of this market
+	AmountExp int           `json:"amount_exp"`        // Number of decimals used for amounts (base asset)
+	PriceExp  int           `json:"price_exp"`         // Number of decimals used for prices (quote asset)
+	SelfTrade SelfTradeMode `json:"stp,omitempty"`     // Default self-trade prevention mode, used if the order has none
+	DayEnd    uint64        `json:"day_end,omitempty"` // Seconds after midnight UTC at which day orders expire
+
+	// Market orders protection: market orders do not match beyond the best price at
+	// entry plus/minus the collar, and any remaining quantity is cancelled. If both
+	// are set, the narrowest one applies.
+	CollarOffset  *Amount `json:"collar,omitempty"`     // Maximum distance from the best price
+	CollarPercent *Amount `json:"collar_pct,omitempty"` // Maximum distance from the best price, as a percentage
+
+	Bands *PriceBands `json:"bands,omitempty"` // Price bands and circuit breaker configuration
+}
+
+// collar returns the worst price a market order of type typ can execute at, given
+// the best price of the opposite side when it entered. Returns nil if there is no
+// limit.
+func (m *Market) collar(typ OrderType, best *Amount) *Amount {
+	offset := m.CollarOffset
+	if offset != nil && offset.exp != best.exp {
+		offset = offset.Dup().SetExp(best.exp)
+	}
+	if m.CollarPercent != nil {
+		pct := percentOf(best, m.CollarPercent)
+		if offset == nil || pct.Cmp(offset) < 0 {
+			offset = pct
+		}
+	}
+	if offset == nil {
+		return nil
+	}
+
+	res := NewAmount(0, best.exp)
+	if typ == TypeBid {
+		return res.Add(best, offset)
+	}
+	return res.Sub(best, offset)
+}
+
+// dayExpiry returns the time at which a day order received at t expires, which is
+// the first end of session strictly after t.
+func (m *Market) dayExpiry(t TimeId) *TimeId {
+	end := t.Unix - t.Unix%86400 + m.DayEnd%86400
+	if end <= t.Unix {
+		end += 86400
+	}
+	return &TimeId{Type: "expire", Unix: end}
+}
+
+// Validate checks that order o can be executed on this market: it must be valid, be
+// for the market's pair, and its amounts must fit the market's precision. Price
+// bands depend on the state of the book and are not checked. o is not modified.
+func (m *Market) Validate(o *Order) error {
+	if err := o.IsValid(); err != nil {
+		return err
+	}
+	if o.Pair != m.Pair {
+		return ErrPairMismatch
+	}
+	return m.normalize(o.Dup())
+}
+
+// normalize ensures the amounts of o use the exponents of the market so that they
+// can be compared with resting orders. Increasing precision is always possible, but
+// an amount that would lose significant digits causes ErrAmountPrecision, in which
+// case o is left unchanged.
+func (m *Market) normalize(o *Order) error {
+	amount, err := fitExp(o.Amount, m.AmountExp)
+	if err != nil {
+		return err
+	}
+	display, err := fitExp(o.Display, m.AmountExp)
+	if err != nil {
+		return err
+	}
+	price, err := fitExp(o.Price, m.PriceExp)
+	if err != nil {
+		return err
+	}
+	stopPrice, err := fitExp(o.StopPrice, m.PriceExp)
+	if err != nil {
+		return err
+	}
+	trailOffset, err := fitExp(o.TrailOffset, m.PriceExp)
+	if err != nil {
+		return err
+	}
+	o.Amount, o.Display, o.Price, o.StopPrice, o.TrailOffset = amount, display, price, stopPrice, trailOffset
+	return nil
+}
+
+// fitExp returns a copy of a using the given exponent, or an error if the
+// conversion would require rounding.
+func fitExp(a *Amount, exp int) (*Amount, error) {
+	if a == nil || a.exp == exp {
+		return a, nil
+	}
+	if a.exp > exp && new(big.Int).Rem(a.value, exp10(a.exp-exp)).Sign() != 0 {
+		return nil, ErrAmountPrecision
+	}
+	return a.Dup().SetExp(exp), nil
+}
@@ -0,0 +1,121 @@
+package ellipxobj
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// Merkle trees hash leaves and nodes with a different prefix so a node can't be
+// presented as a leaf. A node without sibling is promoted to the next level as is.
+const (
+	merkleLeaf = 0x00
+	merkleNode = 0x01
+)
+
+// MerkleProof proves that an order is included in a Checkpoint, by providing the
+// hashes needed to compute its OrderSum from that order only.
+type MerkleProof struct {
+	Index  uint64   `json:"index"`  // Position of the order in the checkpoint, bids first then asks and stops
+	Count  uint64   `json:"count"`  // Number of orders in the checkpoint
+	Hashes [][]byte `json:"hashes"` // Hashes of the siblings of the order's ancestors, from the bottom
+}
+
+// Proof returns a proof that the order with the given Unique id is included in the
+// checkpoint, or ErrOrderNotFound.
+func (c *Checkpoint) Proof(id TimeId) (*MerkleProof, error) {
+	leaves := c.leaves()
+	n := 0
+	for _, side := range c.orders() {
+		for _, o := range side {
+			if o.Unique != nil && o.Unique.Cmp(id) == 0 {
+				return merkleProof(leaves, n), nil
+			}
+			n += 1
+		}
+	}
+	return nil, ErrOrderNotFound
+}
+
+// Verify returns true if the proof shows that order o is included in the
+// checkpoint with the given OrderSum
+func (p *MerkleProof) Verify(o *Order, sum []byte) bool {
+	if p.Index >= p.Count {
+		return false
+	}
+	h := merkleHash(merkleLeaf, o.Bytes(nil))
+	hashes := p.Hashes
+	for idx, n := p.Index, p.Count; n > 1; idx, n = idx/2, (n+1)/2 {
+		if idx^1 >= n {
+			// no sibling, promoted
+			continue
+		}
+		if len(hashes) == 0 {
+			return false
+		}
+		if idx%2 == 0 {
+			h = merkleHash(merkleNode, h, hashes[0])
+		} else {
+			h = merkleHash(merkleNode, hashes[0], h)
+		}
+		hashes = hashes[1:]
+	}
+	return len(hashes) == 0 && bytes.Equal(h, sum)
+}
+
+// leaves returns the leaf hashes of the orders of the checkpoint, bids first then
+// asks and stops
+func (c *Checkpoint) leaves() [][]byte {
+	res := make([][]byte, 0, len(c.Bids)+len(c.Asks)+len(c.Stops))
+	for _, side := range c.orders() {
+		for _, o := range side {
+			res = append(res, merkleHash(merkleLeaf, o.Bytes(nil)))
+		}
+	}
+	return res
+}
+
+func merkleHash(prefix byte, data ...[]byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{prefix})
+	for _, v := range data {
+		h.Write(v)
+	}
+	return h.Sum(nil)
+}
+
+// merkleLevel returns the level above the given level
+func merkleLevel(level [][]byte) [][]byte {
+	res := make([][]byte, 0, (len(level)+1)/2)
+	for n := 0; n < len(level); n += 2 {
+		if n+1 == len(level) {
+			res = append(res, level[n])
+			break
+		}
+		res = append(res, merkleHash(merkleNode, level[n], level[n+1]))
+	}
+	return res
+}
+
+// merkleRoot returns the root of the tree of the given leaves, or the hash of
+// nothing if there are no leaves
+func merkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return merkleHash(merkleLeaf)
+	}
+	for len(leaves) > 1 {
+		leaves = merkleLevel(leaves)
+	}
+	return leaves[0]
+}
+
+// merkleProof returns the proof of inclusion of leaf idx
+func merkleProof(leaves [][]byte, idx int) *MerkleProof {
+	p := &MerkleProof{Index: uint64(idx), Count: uint64(len(leaves))}
+	for len(leaves) > 1 {
+		if sib := idx ^ 1; sib < len(leaves) {
+			p.Hashes = append(p.Hashes, leaves[sib])
+		}
+		leaves, idx = merkleLevel(leaves), idx/2
+	}
+	return p
+}
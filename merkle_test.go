@@ -0,0 +1,40 @@
+package ellipxobj
+
+import (
+	"testing"
+)
+
+func TestCheckpointProof(t *testing.T) {
+	b := NewOrderBook(testMarket())
+	for n, price := range []string{"98", "99", "100", "101", "102"} {
+		typ := TypeBid
+		if n > 2 {
+			typ = TypeAsk
+		}
+		must(b.Execute(testOrder(price, "alice", typ, "1", price)))
+	}
+	c := b.Checkpoint()
+
+	for _, side := range [][]*Order{c.Bids, c.Asks} {
+		for _, o := range side {
+			p := must(c.Proof(*o.Unique))
+			if !p.Verify(o, c.OrderSum) {
+				t.Errorf("proof of order %s failed", o.OrderId)
+			}
+		}
+	}
+
+	o := c.Asks[1].Dup()
+	p := must(c.Proof(*o.Unique))
+	o.Amount = must(NewAmountFromString("2", 8))
+	if p.Verify(o, c.OrderSum) {
+		t.Errorf("proof of modified order must fail")
+	}
+	p.Index = 3
+	if p.Verify(c.Asks[1], c.OrderSum) {
+		t.Errorf("proof with wrong index must fail")
+	}
+	if _, err := c.Proof(TimeId{}); err != ErrOrderNotFound {
+		t.Errorf("expected order not found, got %v", err)
+	}
+}
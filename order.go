@@ -1,9 +1,6 @@
 package ellipxobj
 
-import (
-	"fmt"
-	"time"
-)
+import "fmt"
 
 // Order represents a financial trading order with all parameters needed to execute it.
 // An order can be either a buy (bid) or sell (ask) on a specific trading pair.
@@ -17,21 +14,31 @@ import (
 // Market orders have nil Price, while limit orders specify the desired price.
 // Orders can have various flags that modify their behavior (see OrderFlags).
 type Order struct {
-	OrderId     string      `json:"id"`                    // Unique order ID assigned by the broker
-	BrokerId    string      `json:"iss"`                   // ID of the broker that issued this order
-	UserId      string      `json:"usr,omitempty"`         // Optional ID or hash of the user owner of the order
-	RequestTime uint64      `json:"iat"`                   // Unix timestamp when the order was placed
-	Unique      *TimeId     `json:"uniq,omitempty"`        // Unique ID allocated on order ingress for strict ordering
-	Target      *TimeId     `json:"target,omitempty"`      // Target order to be updated (for order modifications)
-	Version     uint64      `json:"ver"`                   // Version counter, incremented each time order is modified
-	Pair        PairName    `json:"pair"`                  // Trading pair (e.g., BTC_USD)
-	Type        OrderType   `json:"type"`                  // Type of order (BID/ASK, Buy/Sell)
-	Status      OrderStatus `json:"status"`                // Current status of the order (Pending, Open, Filled, etc.)
-	Flags       OrderFlags  `json:"flags,omitempty"`       // Special behavior flags (IOC, FOK, etc.)
-	Amount      *Amount     `json:"amount,omitempty"`      // Quantity of base asset to trade (if nil, SpendLimit must be set)
-	Price       *Amount     `json:"price,omitempty"`       // Limit price (if nil, this is a market order)
-	SpendLimit  *Amount     `json:"spend_limit,omitempty"` // Maximum amount of quote asset to spend/receive (if nil, Amount must be set)
-	StopPrice   *Amount     `json:"stop_price,omitempty"`  // Trigger price for stop orders (ignored if Stop flag not set)
+	OrderId      string        `json:"id"`                    // Unique order ID assigned by the broker
+	BrokerId     string        `json:"iss"`                   // ID of the broker that issued this order
+	UserId       string        `json:"usr,omitempty"`         // Optional ID or hash of the user owner of the order
+	RequestTime  uint64        `json:"iat"`                   // Unix timestamp when the order was placed
+	Unique       *TimeId       `json:"uniq,omitempty"`        // Unique ID allocated on order ingress for strict ordering
+	Target       *TimeId       `json:"target,omitempty"`      // Target order to be updated (for order modifications)
+	Version      uint64        `json:"ver"`                   // Version counter, incremented each time order is modified
+	Pair         PairName      `json:"pair"`                  // Trading pair (e.g., BTC_USD)
+	Type         OrderType     `json:"type"`                  // Type of order (BID/ASK, Buy/Sell)
+	Status       OrderStatus   `json:"status"`                // Current status of the order (Pending, Open, Filled, etc.)
+	Flags        OrderFlags    `json:"flags,omitempty"`       // Special behavior flags (IOC, FOK, etc.)
+	Amount       *Amount       `json:"amount,omitempty"`      // Quantity of base asset to trade (if nil, SpendLimit must be set)
+	Price        *Amount       `json:"price,omitempty"`       // Limit price (if nil, this is a market order)
+	SpendLimit   *Amount       `json:"spend_limit,omitempty"` // Maximum amount of quote asset to spend/receive (if nil, Amount must be set)
+	StopPrice    *Amount       `json:"stop_price,omitempty"`  // Trigger price for stop orders (ignored if Stop flag not set)
+	TrailOffset  *Amount       `json:"trail,omitempty"`       // Trailing stop orders: distance between StopPrice and the best price
+	TrailPercent *Amount       `json:"trail_pct,omitempty"`   // Trailing stop orders: distance as a percentage of the best price
+	TrailRef     *Amount       `json:"trail_ref,omitempty"`   // Trailing stop orders: best price since the order was placed
+	SelfTrade    SelfTradeMode `json:"stp,omitempty"`         // Self-trade prevention mode (if none, the market's default is used)
+	TimeInForce  TimeInForce   `json:"tif,omitempty"`         // How long the order stays in the book (GTC if not set)
+	Expires      *TimeId       `json:"expires,omitempty"`     // Expiry time for GTD orders, computed by the book for day orders
+	Display      *Amount       `json:"display,omitempty"`     // Iceberg orders: quantity shown in the book at any time (if nil, all of Amount is shown)
+	Visible      *Amount       `json:"visible,omitempty"`     // Iceberg orders: remaining quantity of the currently shown slice
+	Priority     *TimeId       `json:"priority,omitempty"`    // Iceberg orders: time priority of the currently shown slice (Unique if nil)
+	Group        string        `json:"group,omitempty"`       // Id of the OrderGroup this order belongs to, if any
 }
 
 type OrderMeta struct {
@@ -52,8 +59,17 @@ type OrderMeta struct {
 //	    SetAmount(NewAmount(10000, 8)). // 0.1 BTC
 //	    SetPrice(NewAmount(2000000, 2)) // $20,000.00
 func NewOrder(pair PairName, typ OrderType) *Order {
+	return NewOrderWithClock(pair, typ, nil)
+}
+
+// NewOrderWithClock is the same as NewOrder but initializes the order with the time
+// of clock c, or the system time if c is nil.
+func NewOrderWithClock(pair PairName, typ OrderType, c Clock) *Order {
+	if c == nil {
+		c = SystemClock{}
+	}
 	res := &Order{
-		RequestTime: uint64(time.Now().Unix()),
+		RequestTime: uint64(c.Now().Unix()),
 		Pair:        pair,
 		Type:        typ,
 		Status:      OrderPending,
@@ -87,6 +103,25 @@ func (o *Order) IsValid() error {
 	if o.Amount == nil && o.SpendLimit == nil {
 		return ErrOrderNeedsAmount
 	}
+	if !o.TimeInForce.IsValid() {
+		return ErrTimeInForceNotValid
+	}
+	if o.TimeInForce == TimeInForceGTD && o.Expires == nil {
+		return ErrOrderExpiryMissing
+	}
+	if o.Display != nil && (o.Display.Sign() <= 0 || o.Price == nil) {
+		return ErrDisplayNotValid
+	}
+	if o.TrailOffset != nil || o.TrailPercent != nil {
+		if !o.Flags.Has(FlagStop) || (o.TrailOffset != nil && o.TrailPercent != nil) {
+			return ErrTrailNotValid
+		}
+		if (o.TrailOffset != nil && o.TrailOffset.Sign() <= 0) || (o.TrailPercent != nil && o.TrailPercent.Sign() <= 0) {
+			return ErrTrailNotValid
+		}
+	} else if o.Flags.Has(FlagStop) && o.StopPrice == nil {
+		return ErrStopPriceMissing
+	}
 
 	return nil
 }
@@ -134,14 +169,80 @@ func (o *Order) Dup() *Order {
 		*res.Unique = *o.Unique
 	}
 
+	if o.Expires != nil {
+		res.Expires = &TimeId{}
+		*res.Expires = *o.Expires
+	}
+
+	if o.Priority != nil {
+		res.Priority = &TimeId{}
+		*res.Priority = *o.Priority
+	}
+
 	res.Amount = o.Amount.Dup()
 	res.Price = o.Price.Dup()
 	res.SpendLimit = o.SpendLimit.Dup()
 	res.StopPrice = o.StopPrice.Dup()
+	res.TrailOffset = o.TrailOffset.Dup()
+	res.TrailPercent = o.TrailPercent.Dup()
+	res.TrailRef = o.TrailRef.Dup()
+	res.Display = o.Display.Dup()
+	res.Visible = o.Visible.Dup()
 
 	return res
 }
 
+// Public returns a copy of the order as it can be shown to other market participants.
+// For iceberg orders, Amount is set to the visible quantity and the Display,
+// Visible, Priority and SpendLimit fields are removed so the hidden quantity is not
+// disclosed.
+func (o *Order) Public() *Order {
+	res := o.Dup()
+	if res.Display != nil {
+		res.Amount = o.VisibleAmount().Dup()
+		res.Display = nil
+		res.Visible = nil
+		res.Priority = nil
+		res.SpendLimit = nil
+	}
+	return res
+}
+
+// VisibleAmount returns the quantity of this order that is shown in the book. For
+// iceberg orders this is the remaining quantity of the current slice, for other
+// orders this is Amount.
+func (o *Order) VisibleAmount() *Amount {
+	if o.Display == nil || o.Amount == nil {
+		return o.Amount
+	}
+	if o.Visible != nil {
+		return o.Visible
+	}
+	if o.Display.Cmp(o.Amount) < 0 {
+		return o.Display
+	}
+	return o.Amount
+}
+
+// priority returns the time priority of the order in the book: the Priority of its
+// current slice for iceberg orders that were replenished, its Unique id otherwise
+func (o *Order) priority() *TimeId {
+	if o.Priority != nil {
+		return o.Priority
+	}
+	return o.Unique
+}
+
+// showSlice sets Visible to a new slice of Display (or the remaining Amount if
+// smaller). It does nothing if the order is not an iceberg order.
+func (o *Order) showSlice() {
+	if o.Display == nil || o.Amount == nil {
+		return
+	}
+	o.Visible = nil
+	o.Visible = o.VisibleAmount().Dup()
+}
+
 func (o *Order) String() string {
 	// transform order into a human friendly string, there are various cases we can process
 	if err := o.IsValid(); err != nil {
@@ -168,6 +269,20 @@ func (o *Order) String() string {
 	return res
 }
 
+// IsTriggered returns true if this stop order should be triggered given the price of
+// the last trade: buy orders trigger when the price rises to StopPrice or above, and
+// sell orders when it falls to StopPrice or below.
+func (o *Order) IsTriggered(last *Amount) bool {
+	if o.StopPrice == nil || last == nil {
+		return false
+	}
+	c := last.Cmp(o.StopPrice)
+	if o.Type == TypeBid {
+		return c >= 0
+	}
+	return c <= 0
+}
+
 // NominalAmount calculates and returns the effective quantity of the base asset
 // that would be traded, considering both Amount and SpendLimit constraints.
 //
@@ -341,6 +456,9 @@ func (a *Order) Matches(b *Order) *Trade {
 // - For SpendLimit-based orders: Reduces SpendLimit by the trade's Spent value
 // - For orders with both: Reduces both values appropriately
 //
+// For iceberg orders, Visible is also reduced (but never below zero) so the caller
+// can detect when a new slice needs to be shown.
+//
 // Returns true if the order is fully consumed (either Amount or SpendLimit reduced to zero).
 // Note that even if this method returns false (order not fully consumed), the remaining
 // quantity might be too small to execute further trades.
@@ -357,6 +475,14 @@ func (o *Order) Deduct(t *Trade) bool {
 		}
 	}
 
+	// Update the visible slice of iceberg orders
+	if o.Visible != nil {
+		o.Visible = o.Visible.Sub(o.Visible, t.Amount)
+		if o.Visible.Sign() < 0 {
+			o.Visible = NewAmount(0, o.Visible.exp)
+		}
+	}
+
 	// Update SpendLimit if set
 	if o.SpendLimit != nil {
 		o.SpendLimit = o.SpendLimit.Sub(o.SpendLimit, t.Spent())
@@ -369,3 +495,55 @@ func (o *Order) Deduct(t *Trade) bool {
 
 	return fullyConsumed
 }
+
+// Amend applies the changes requested by order n to this order. n must have its
+// Target set to this order's Unique id, and must have the same pair, type, broker
+// and user. Amount and SpendLimit in n, if set, are the new remaining quantities,
+// and Price the new limit price. A market order cannot be made into a limit order.
+//
+// Version is incremented on success. The returned bool is true if the order can keep
+// its time priority, which is only the case when quantities are not increased and
+// the price does not change.
+//
+// Amounts in n are expected to use the same exponents as this order's.
+func (o *Order) Amend(n *Order) (bool, error) {
+	if n.Target == nil || o.Unique == nil || n.Target.Cmp(*o.Unique) != 0 {
+		return false, ErrAmendTargetMismatch
+	}
+	if n.Pair != o.Pair || n.Type != o.Type || n.BrokerId != o.BrokerId || n.UserId != o.UserId {
+		return false, ErrAmendNotCompatible
+	}
+	if (n.Amount != nil && n.Amount.Sign() <= 0) || (n.SpendLimit != nil && n.SpendLimit.Sign() <= 0) {
+		return false, ErrOrderNeedsAmount
+	}
+	if n.Price != nil && o.Price == nil {
+		return false, ErrAmendNotCompatible
+	}
+
+	keep := true
+	if n.Price != nil && n.Price.Cmp(o.Price) != 0 {
+		keep = false
+		o.Price = n.Price.Dup()
+	}
+	if n.Amount != nil {
+		if o.Amount == nil || n.Amount.Cmp(o.Amount) > 0 {
+			keep = false
+		}
+		o.Amount = n.Amount.Dup()
+		if o.Visible != nil && o.Visible.Cmp(o.Amount) > 0 {
+			o.Visible = o.Amount.Dup()
+		}
+	}
+	if n.SpendLimit != nil {
+		if o.SpendLimit == nil || n.SpendLimit.Cmp(o.SpendLimit) > 0 {
+			keep = false
+		}
+		o.SpendLimit = n.SpendLimit.Dup()
+	}
+	if n.StopPrice != nil && o.Flags.Has(FlagStop) {
+		o.StopPrice = n.StopPrice.Dup()
+	}
+
+	o.Version += 1
+	return keep, nil
+}
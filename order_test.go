@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"testing"
+	"time"
 )
 
 func TestOrder(t *testing.T) {
@@ -112,3 +113,22 @@ func TestMatchSmallOrder(t *testing.T) {
 	log.Printf("order A = comp=%v %+v", compa, a)
 	log.Printf("order B = comp=%v %+v", compb, b)
 }
+
+func TestOrderClock(t *testing.T) {
+	clock := NewManualClock(time.Unix(1715773941, 500))
+
+	o := NewOrderWithClock(Pair("BTC", "USD"), TypeBid, clock)
+	if o.RequestTime != 1715773941 {
+		t.Errorf("unexpected request time %d", o.RequestTime)
+	}
+
+	u := &TimeIdUnique{Clock: clock}
+	a, b := u.New(), u.New()
+	if a.String() != "nil:1715773941:500:0" || b.String() != "nil:1715773941:500:1" {
+		t.Errorf("unexpected ids %s %s", a, b)
+	}
+	clock.Advance(time.Second)
+	if c := u.New(); c.String() != "nil:1715773942:500:0" {
+		t.Errorf("unexpected id after advance %s", c)
+	}
+}
@@ -0,0 +1,249 @@
+package ellipxobj
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Bytes returns a canonical binary encoding of the order, suitable for hashing. If
+// buf is not nil, the data is appended to it. Two orders have the same encoding if
+// and only if all their fields are equal.
+func (o *Order) Bytes(buf []byte) []byte {
+	buf = append(buf, 0x00) // version
+	buf = appendString(buf, o.OrderId)
+	buf = appendString(buf, o.BrokerId)
+	buf = appendString(buf, o.UserId)
+	buf = binary.AppendUvarint(buf, o.RequestTime)
+	buf = appendTimeId(buf, o.Unique)
+	buf = appendTimeId(buf, o.Target)
+	buf = binary.AppendUvarint(buf, o.Version)
+	buf = appendString(buf, o.Pair[0])
+	buf = appendString(buf, o.Pair[1])
+	buf = binary.AppendVarint(buf, int64(o.Type))
+	buf = binary.AppendVarint(buf, int64(o.Status))
+	buf = binary.AppendVarint(buf, int64(o.Flags))
+	buf = appendAmount(buf, o.Amount)
+	buf = appendAmount(buf, o.Price)
+	buf = appendAmount(buf, o.SpendLimit)
+	buf = appendAmount(buf, o.StopPrice)
+	buf = appendAmount(buf, o.TrailOffset)
+	buf = appendAmount(buf, o.TrailPercent)
+	buf = appendAmount(buf, o.TrailRef)
+	buf = binary.AppendVarint(buf, int64(o.SelfTrade))
+	buf = binary.AppendVarint(buf, int64(o.TimeInForce))
+	buf = appendTimeId(buf, o.Expires)
+	buf = appendAmount(buf, o.Display)
+	buf = appendAmount(buf, o.Visible)
+	buf = appendTimeId(buf, o.Priority)
+	return appendString(buf, o.Group)
+}
+
+func (o *Order) MarshalBinary() ([]byte, error) {
+	return o.Bytes(nil), nil
+}
+
+// UnmarshalBinary decodes an order encoded by Bytes
+func (o *Order) UnmarshalBinary(data []byte) error {
+	r := &binReader{data: data}
+	if r.byte() != 0x00 {
+		return errors.New("invalid order encoding version")
+	}
+	res := &Order{
+		OrderId:     r.string(),
+		BrokerId:    r.string(),
+		UserId:      r.string(),
+		RequestTime: r.uvarint(),
+		Unique:      r.timeId(),
+		Target:      r.timeId(),
+		Version:     r.uvarint(),
+		Pair:        PairName{r.string(), r.string()},
+		Type:        OrderType(r.varint()),
+		Status:      OrderStatus(r.varint()),
+		Flags:       OrderFlags(r.varint()),
+	}
+	res.Amount = r.amount()
+	res.Price = r.amount()
+	res.SpendLimit = r.amount()
+	res.StopPrice = r.amount()
+	res.TrailOffset = r.amount()
+	res.TrailPercent = r.amount()
+	res.TrailRef = r.amount()
+	res.SelfTrade = SelfTradeMode(r.varint())
+	res.TimeInForce = TimeInForce(r.varint())
+	res.Expires = r.timeId()
+	res.Display = r.amount()
+	res.Visible = r.amount()
+	res.Priority = r.timeId()
+	res.Group = r.string()
+
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.data) != 0 {
+		return errors.New("trailing data after order")
+	}
+	*o = *res
+	return nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// appendTimeId appends t with its type, or a single zero byte if t is nil
+func appendTimeId(buf []byte, t *TimeId) []byte {
+	if t == nil {
+		return append(buf, 0)
+	}
+	buf = append(buf, 1)
+	buf = appendString(buf, t.Type)
+	return t.Bytes(buf)
+}
+
+// appendAmount appends the length prefixed encoding of a, or a single zero byte if
+// a is nil
+func appendAmount(buf []byte, a *Amount) []byte {
+	if a == nil {
+		return append(buf, 0)
+	}
+	v := a.Bytes()
+	buf = binary.AppendUvarint(buf, uint64(len(v)))
+	return append(buf, v...)
+}
+
+// appendGroup appends the encoding of group g and its orders, including the state
+// of bracket groups
+func appendGroup(buf []byte, g *OrderGroup) []byte {
+	buf = appendString(buf, g.Id)
+	buf = binary.AppendVarint(buf, int64(g.Type))
+	buf = appendAmount(buf, g.Filled)
+	if g.Active {
+		buf = append(buf, 1)
+	} else {
+		buf = append(buf, 0)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(g.Orders)))
+	for _, o := range g.Orders {
+		buf = appendBytes(buf, o.Bytes(nil))
+	}
+	return buf
+}
+
+var errBinaryShort = errors.New("binary data too short")
+
+// binReader decodes data encoded with the append functions. After the first error,
+// all reads return zero values and err is set.
+type binReader struct {
+	data []byte
+	err  error
+}
+
+func (r *binReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = errBinaryShort
+		return nil
+	}
+	res := r.data[:n]
+	r.data = r.data[n:]
+	return res
+}
+
+func (r *binReader) byte() byte {
+	v := r.bytes(1)
+	if v == nil {
+		return 0
+	}
+	return v[0]
+}
+
+func (r *binReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errBinaryShort
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = errBinaryShort
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binReader) string() string {
+	return string(r.bytes(int(r.uvarint())))
+}
+
+// blob reads data written by appendBytes, returning nil for empty data
+func (r *binReader) blob() []byte {
+	v := r.bytes(int(r.uvarint()))
+	if len(v) == 0 {
+		return nil
+	}
+	return append([]byte(nil), v...)
+}
+
+func (r *binReader) timeId() *TimeId {
+	if r.byte() == 0 {
+		return nil
+	}
+	typ := r.string()
+	t := &TimeId{}
+	if v := r.bytes(TimeIdDataLen); v != nil {
+		t.UnmarshalBinary(v)
+	}
+	t.Type = typ
+	return t
+}
+
+func (r *binReader) amount() *Amount {
+	n := int(r.uvarint())
+	if n == 0 {
+		return nil
+	}
+	v := r.bytes(n)
+	if v == nil {
+		return nil
+	}
+	a := &Amount{}
+	if err := a.UnmarshalBinary(v); err != nil {
+		r.err = err
+		return nil
+	}
+	return a
+}
+
+func (r *binReader) group() *OrderGroup {
+	g := &OrderGroup{
+		Id:     r.string(),
+		Type:   GroupType(r.varint()),
+		Filled: r.amount(),
+		Active: r.byte() != 0,
+	}
+	n := r.uvarint()
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		o := &Order{}
+		if err := o.UnmarshalBinary(r.bytes(int(r.uvarint()))); err != nil {
+			r.err = err
+			return nil
+		}
+		g.Orders = append(g.Orders, o)
+	}
+	return g
+}
@@ -22,6 +22,10 @@ func (f *OrderFlags) UnmarshalJSON(j []byte) error {
 	var flags []string
 	var res OrderFlags
 
+	if err := json.Unmarshal(j, &flags); err != nil {
+		return err
+	}
+
 	for _, s := range flags {
 		switch s {
 		case "ioc":
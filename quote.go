@@ -0,0 +1,131 @@
+package ellipxobj
+
+// Quote is an estimation of the execution of an order, for display before the
+// order is submitted
+type Quote struct {
+	Type       OrderType `json:"type"`
+	Amount     *Amount   `json:"amount"`             // Quantity of base asset that would be traded
+	Spent      *Amount   `json:"spent"`              // Quantity of quote asset that would be traded
+	AvgPrice   *Amount   `json:"avg_price"`          // Average execution price (nil if nothing would be traded)
+	WorstPrice *Amount   `json:"worst_price"`        // Price of the last level reached (nil if nothing would be traded)
+	Mid        *Amount   `json:"mid,omitempty"`      // Mid price of the book (nil if one side is empty)
+	Slippage   *Amount   `json:"slippage,omitempty"` // Percentage by which the average price is worse than the mid price
+	Complete   bool      `json:"complete"`           // True if the order would be fully executed
+}
+
+// Quote estimates the execution of order o on the book. Only the order's Type,
+// Amount, SpendLimit and Price are relevant, so a quote can be obtained for an
+// incomplete order. See Simulate for the details.
+func (b *OrderBook) Quote(o *Order) (*Quote, error) {
+	fill, err := b.Simulate(o)
+	if err != nil {
+		return nil, err
+	}
+	var mid *Amount
+	if len(b.Bids) > 0 && len(b.Asks) > 0 {
+		mid = midPrice(b.Bids[0].Price, b.Asks[0].Price)
+	}
+	return newQuote(o.Type, fill, mid, b.Market.PriceExp), nil
+}
+
+// Mid returns the mid price of the depth snapshot, or nil if one side is empty
+func (d *Depth) Mid() *Amount {
+	if len(d.Bids) == 0 || len(d.Asks) == 0 {
+		return nil
+	}
+	return midPrice(d.Bids[0].Price, d.Asks[0].Price)
+}
+
+// Quote estimates the execution of order o against this depth snapshot, by
+// matching it against each level as if it was a single order. Quantities are
+// computed the same way as actual matching (see Order.TradeAmount). Since a
+// snapshot does not include hidden quantities, self-trade prevention or price
+// bands, the actual execution may differ.
+//
+// The amounts of o must fit the precision of the snapshot's levels, or
+// ErrAmountPrecision is returned.
+func (d *Depth) Quote(o *Order) (*Quote, error) {
+	levels := d.Asks
+	if o.Type == TypeAsk {
+		levels = d.Bids
+	}
+
+	amountExp, priceExp := 0, 0
+	if len(levels) > 0 {
+		amountExp, priceExp = levels[0].Amount.exp, levels[0].Price.exp
+		m := &Market{Pair: d.Pair, AmountExp: amountExp, PriceExp: priceExp}
+		o = o.Dup()
+		if err := m.normalize(o); err != nil {
+			return nil, err
+		}
+	}
+	fill := &Fill{
+		Amount: NewAmount(0, amountExp),
+		Spent:  NewAmount(0, priceExp),
+	}
+
+	taker := &Order{Pair: d.Pair, Type: o.Type, Price: o.Price, Amount: o.Amount.Dup(), SpendLimit: o.SpendLimit.Dup()}
+	var last *Amount
+	for _, l := range levels {
+		if isExhausted(taker) {
+			break
+		}
+		t := taker.Matches(&Order{Type: o.Type.Reverse(), Price: l.Price, Amount: l.Amount})
+		if t == nil {
+			break
+		}
+		fill.Trades = append(fill.Trades, t)
+		fill.Amount = fill.Amount.Add(fill.Amount, t.Amount)
+		spent := t.Spent()
+		fill.Spent = fill.Spent.Add(fill.Spent, spent)
+		last = l.Price
+
+		if taker.Amount != nil {
+			taker.Amount = taker.Amount.Sub(taker.Amount, t.Amount)
+		}
+		if taker.SpendLimit != nil {
+			taker.SpendLimit = taker.SpendLimit.Sub(taker.SpendLimit, spent)
+		}
+	}
+
+	fill.Complete = isExhausted(taker)
+	if !fill.Complete && last != nil {
+		unit := &Order{Price: last, Amount: NewAmount(1, amountExp)}
+		fill.Complete = taker.TradeAmount(unit).Sign() <= 0
+	}
+
+	return newQuote(o.Type, fill, d.Mid(), priceExp), nil
+}
+
+func newQuote(typ OrderType, fill *Fill, mid *Amount, priceExp int) *Quote {
+	res := &Quote{
+		Type:     typ,
+		Amount:   fill.Amount,
+		Spent:    fill.Spent,
+		Mid:      mid,
+		Complete: fill.Complete,
+	}
+	if fill.Amount.Sign() <= 0 {
+		return res
+	}
+
+	res.AvgPrice = NewAmount(0, priceExp).Div(fill.Spent, fill.Amount)
+	res.WorstPrice = fill.Trades[len(fill.Trades)-1].Price
+
+	if mid != nil && mid.Sign() > 0 {
+		// slippage = (avg - mid) / mid * 100, reversed for sell orders
+		diff := NewAmount(0, priceExp).Sub(res.AvgPrice, mid)
+		if typ == TypeAsk {
+			diff = diff.Neg()
+		}
+		diff = NewAmount(0, priceExp).Mul(diff, NewAmount(100, 0))
+		res.Slippage = NewAmount(0, priceExp).Div(diff, mid)
+	}
+	return res
+}
+
+// midPrice returns the average of two prices
+func midPrice(a, b *Amount) *Amount {
+	res := NewAmount(0, a.exp).Add(a, b)
+	return NewAmount(0, a.exp).Div(res, NewAmount(2, 0))
+}
@@ -0,0 +1,51 @@
+package ellipxobj
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	b := NewOrderBook(testMarket())
+	must(b.Execute(testOrder("b1", "bob", TypeBid, "1", "98")))
+	must(b.Execute(testOrder("a1", "alice", TypeAsk, "1", "100")))
+	must(b.Execute(testOrder("a2", "alice", TypeAsk, "1", "104")))
+
+	buy := testOrder("c1", "carol", TypeBid, "1.5", "")
+	q := must(b.Depth(0).Quote(buy))
+	if !q.Complete || q.Spent.String() != "152.00000" || q.AvgPrice.String() != "101.33333" || q.WorstPrice.String() != "104.00000" {
+		t.Errorf("unexpected quote %+v", q)
+	}
+	// mid is 99, (101.33333 - 99) / 99 = 2.35689% (rounded down)
+	if q.Mid.String() != "99.00000" || q.Slippage.String() != "2.35689" {
+		t.Errorf("unexpected slippage %s mid %s", q.Slippage, q.Mid)
+	}
+
+	sell := testOrder("c2", "carol", TypeAsk, "2", "")
+	sell.Amount = nil
+	sell.SpendLimit = must(NewAmountFromString("49", 5))
+	q = must(b.Quote(sell))
+	if !q.Complete || q.Amount.String() != "0.50000000" || q.Slippage.String() != "1.01010" {
+		t.Errorf("unexpected sell quote %+v", q)
+	}
+	if len(b.Asks) != 2 || b.Bids[0].Amount.String() != "1.00000000" {
+		t.Errorf("quote must not modify the book")
+	}
+
+	// amounts are converted to the precision of the book
+	limit := testOrder("c3", "carol", TypeBid, "2", "")
+	limit.Price = must(NewAmountFromString("101", 2))
+	if q := must(b.Quote(limit)); q.Complete || q.Amount.String() != "1.00000000" {
+		t.Errorf("unexpected limit quote %+v", q)
+	}
+	if q := must(b.Depth(0).Quote(limit)); q.Complete || q.Amount.String() != "1.00000000" {
+		t.Errorf("unexpected limit depth quote %+v", q)
+	}
+	limit.Price = must(NewAmountFromString("100.123456", 6))
+	if _, err := b.Quote(limit); !errors.Is(err, ErrAmountPrecision) {
+		t.Errorf("expected ErrAmountPrecision, got %v", err)
+	}
+	if _, err := b.Depth(0).Quote(limit); !errors.Is(err, ErrAmountPrecision) {
+		t.Errorf("expected ErrAmountPrecision from depth quote, got %v", err)
+	}
+}
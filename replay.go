@@ -0,0 +1,168 @@
+package ellipxobj
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ReplayDivergence is returned by VerifyReplay when the replayed history differs
+// from the recorded one.
+type ReplayDivergence struct {
+	Index    int    // Index of the first divergent event, or -1 if only the final state differs
+	Expected *Event // Recorded event, nil if the replay generated more events
+	Actual   *Event // Replayed event, nil if the replay generated fewer events
+}
+
+func (d *ReplayDivergence) Error() string {
+	if d.Index < 0 {
+		return ErrReplayDiverged.Error() + ": order sum mismatch"
+	}
+	return fmt.Sprintf("%s at event %d: expected %v, got %v", ErrReplayDiverged, d.Index, d.Expected, d.Actual)
+}
+
+func (d *ReplayDivergence) Unwrap() error {
+	return ErrReplayDiverged
+}
+
+// Replay restores a book for market m from checkpoint c, then executes orders in
+// sequence and returns the resulting book and all the events generated.
+//
+// The result is deterministic: the book uses a ManualClock set to the time of each
+// order before executing it, which is its Unique id if set (as allocated on ingress)
+// or its RequestTime otherwise. A book processing live orders the same way will
+// generate the same ids. Orders with a Target are amendments (see OrderBook.Amend),
+// the others are executed. Orders are not modified.
+//
+// Since orders come from a history that was already processed, an order rejected
+// by the book means the history does not apply to c, and the error is returned.
+func Replay(m *Market, c *Checkpoint, orders []*Order) (*OrderBook, []*Event, error) {
+	b, err := RestoreOrderBook(m, c)
+	if err != nil {
+		return nil, nil, err
+	}
+	clock := &ManualClock{}
+	b.Clock = clock
+
+	var res []*Event
+	for n, o := range orders {
+		if o.Unique != nil {
+			clock.Set(o.Unique.Time())
+		} else {
+			clock.Set(time.Unix(int64(o.RequestTime), 0))
+		}
+		var events []*Event
+		if o.Target != nil {
+			events, err = b.Amend(o.Dup())
+		} else {
+			events, err = b.Execute(o.Dup())
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("replay order %d: %w", n, err)
+		}
+		res = append(res, events...)
+	}
+	return b, res, nil
+}
+
+// VerifyReplay replays orders from checkpoint from (see Replay), and compares the
+// generated events with the expected ones, then the state of the book with the
+// OrderSum of checkpoint to. A *ReplayDivergence describing the first difference
+// is returned if they do not match. Expected can be nil to only compare the final
+// state.
+func VerifyReplay(m *Market, from *Checkpoint, orders []*Order, expected []*Event, to *Checkpoint) error {
+	b, events, err := Replay(m, from, orders)
+	if err != nil {
+		return err
+	}
+
+	if expected != nil {
+		for n := 0; n < max(len(events), len(expected)); n++ {
+			d := &ReplayDivergence{Index: n}
+			if n < len(expected) {
+				d.Expected = expected[n]
+			}
+			if n < len(events) {
+				d.Actual = events[n]
+			}
+			if !sameEvent(d.Expected, d.Actual) {
+				return d
+			}
+		}
+	}
+
+	if !bytes.Equal(b.Checkpoint().OrderSum, to.OrderSum) {
+		return &ReplayDivergence{Index: -1}
+	}
+	return nil
+}
+
+// ReplayJournal restores a book for market m from checkpoint c, then applies the
+// commands of the journal at path recorded after c.Point (see OrderBook.Apply), and
+// returns the resulting book and all the events generated.
+//
+// The events recorded after each command must be identical to the ones the command
+// generates, otherwise a *ReplayDivergence is returned. This is also the case for
+// journals holding events without commands, which can't be replayed. Only the last
+// command may have fewer events recorded, since its events may not have been fully
+// written (see OpenJournal).
+func ReplayJournal(m *Market, c *Checkpoint, path string) (*OrderBook, []*Event, error) {
+	b, err := RestoreOrderBook(m, c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var res, pending []*Event
+	n := 0 // index of the next recorded event
+	err = ReadJournal(path, c.Point, func(rec *JournalRecord) error {
+		if ev := rec.Event; ev != nil {
+			if len(pending) == 0 || !sameEvent(ev, pending[0]) {
+				d := &ReplayDivergence{Index: n, Expected: ev}
+				if len(pending) > 0 {
+					d.Actual = pending[0]
+				}
+				return d
+			}
+			pending = pending[1:]
+			n += 1
+			return nil
+		}
+
+		if len(pending) > 0 {
+			// events of the previous command are missing
+			return &ReplayDivergence{Index: n, Actual: pending[0]}
+		}
+		if rec.Command.Id == nil {
+			// commands are journaled once applied, so they have an id
+			return fmt.Errorf("replay command %s: %w", rec.Command, ErrCommandNotValid)
+		}
+		events, err := b.Apply(rec.Command)
+		if err != nil {
+			return fmt.Errorf("replay command %s: %w", rec.Command, err)
+		}
+		res = append(res, events...)
+		pending = events
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, res, nil
+}
+
+// sameEvent returns true if both events are non nil and have the same encoding
+func sameEvent(a, b *Event) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ja, jb)
+}
@@ -0,0 +1,157 @@
+package ellipxobj
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	clock := &ManualClock{}
+	b := NewOrderBook(testMarket())
+	b.Clock = clock
+
+	ingress := func(o *Order, sec uint64) *Order {
+		o.Unique = &TimeId{Type: "order", Unix: 1700000000 + sec}
+		return o
+	}
+	must(b.Execute(ingress(testOrder("a1", "alice", TypeAsk, "1", "100"), 0)))
+	from := b.Checkpoint()
+
+	orders := []*Order{
+		ingress(testOrder("a2", "alice", TypeAsk, "1", "101"), 1),
+		ingress(testOrder("b1", "bob", TypeBid, "1.5", "101"), 2),
+		ingress(testOrder("b2", "bob", TypeBid, "1", "99"), 3),
+		ingress(testOrder("b2", "bob", TypeBid, "1", "98"), 4),
+	}
+	orders[3].Target = orders[2].Unique
+	var expected []*Event
+	for _, o := range orders {
+		clock.Set(o.Unique.Time())
+		if o.Target != nil {
+			expected = append(expected, must(b.Amend(o.Dup()))...)
+		} else {
+			expected = append(expected, must(b.Execute(o.Dup()))...)
+		}
+	}
+	to := b.Checkpoint()
+
+	if err := VerifyReplay(b.Market, from, orders, expected, to); err != nil {
+		t.Errorf("replay failed: %s", err)
+	}
+
+	// a different order causes the trades to diverge
+	changed := append([]*Order{}, orders...)
+	changed[1] = changed[1].Dup()
+	changed[1].Amount = must(NewAmountFromString("1.2", 8))
+	var d *ReplayDivergence
+	err := VerifyReplay(b.Market, from, changed, expected, to)
+	if !errors.As(err, &d) || d.Index != 2 || d.Expected.Type != EventAccept || !errors.Is(err, ErrReplayDiverged) {
+		t.Errorf("unexpected divergence %v", err)
+	}
+	// without events, only the final state is compared
+	if err = VerifyReplay(b.Market, from, changed, nil, to); !errors.As(err, &d) || d.Index != -1 {
+		t.Errorf("unexpected divergence %v", err)
+	}
+
+	// an order the book rejects means the history does not apply
+	bad := append([]*Order{}, orders...)
+	bad[1] = bad[1].Dup()
+	bad[1].Pair = Pair("ETH", "USD")
+	if _, _, err = Replay(b.Market, from, bad); !errors.Is(err, ErrPairMismatch) {
+		t.Errorf("expected pair mismatch, got %v", err)
+	}
+
+	from.OrderSum[0] ^= 0xff
+	if _, _, err = Replay(b.Market, from, orders); err != ErrOrderSumMismatch {
+		t.Errorf("expected order sum mismatch, got %v", err)
+	}
+}
+
+func TestReplayJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.journal")
+	j := must(OpenJournal(path))
+	b := NewOrderBook(testMarket())
+	apply := func(c *Command) {
+		if err := j.WriteCommand(c, must(b.Apply(c))...); err != nil {
+			t.Fatalf("failed to write journal: %s", err)
+		}
+	}
+
+	must(b.Execute(testOrder("b1", "bob", TypeBid, "1", "90")))
+	from := b.Checkpoint()
+	ice := testOrder("a1", "alice", TypeAsk, "3", "100")
+	ice.Display = must(NewAmountFromString("1", 8))
+	apply(&Command{Type: CommandExecute, Order: ice})
+	apply(&Command{Type: CommandExecute, Order: testOrder("b2", "bob", TypeBid, "1.5", "100")})
+	apply(&Command{Type: CommandExecute, Order: testOrder("b3", "carol", TypeBid, "0.2", "100")})
+	j.Close()
+
+	r, events, err := ReplayJournal(b.Market, from, path)
+	if err != nil {
+		t.Fatalf("failed to replay journal: %s", err)
+	}
+	if len(events) == 0 || !bytes.Equal(r.Checkpoint().Sum(), b.Checkpoint().Sum()) {
+		t.Errorf("replayed book differs")
+	}
+
+	// starting from another state, the replayed events diverge at the first trade
+	other := NewOrderBook(testMarket())
+	must(other.Execute(testOrder("a0", "dave", TypeAsk, "1", "99")))
+	diverged := other.Checkpoint()
+	diverged.Point = from.Point
+	var d *ReplayDivergence
+	if _, _, err = ReplayJournal(b.Market, diverged, path); !errors.As(err, &d) || d.Index != 3 || d.Expected.Type != EventTrade {
+		t.Errorf("unexpected divergence %v", err)
+	}
+
+	// events without commands can't be replayed
+	path = filepath.Join(t.TempDir(), "events.journal")
+	j = must(OpenJournal(path))
+	j.Write(must(NewOrderBook(testMarket()).Execute(testOrder("a1", "alice", TypeAsk, "1", "100")))...)
+	j.Close()
+	if _, _, err = ReplayJournal(b.Market, &Checkpoint{Pair: b.Market.Pair}, path); !errors.As(err, &d) || d.Actual != nil {
+		t.Errorf("expected divergence, got %v", err)
+	}
+}
+
+func TestRestoreOrderBook(t *testing.T) {
+	b := NewOrderBook(testMarket())
+	must(b.Execute(testOrder("b1", "bob", TypeBid, "1", "99")))
+	must(b.Execute(testOrder("a1", "carol", TypeAsk, "0.5", "100")))
+	must(b.Execute(testOrder("b2", "bob", TypeBid, "0.5", "100")))
+	tp := testOrder("a2", "alice", TypeAsk, "1", "110")
+	sl := testStop(testOrder("a3", "alice", TypeAsk, "1", ""), "95")
+	must(b.ExecuteGroup(&OrderGroup{Id: "g1", Type: GroupOCO, Orders: []*Order{tp, sl}}))
+	b.Halt("test")
+
+	c := b.Checkpoint()
+	if len(c.Stops) != 1 || len(c.Groups) != 1 || c.LastPrice == nil || !c.Halted || c.OrderCount != 3 {
+		t.Fatalf("incomplete checkpoint %+v", c)
+	}
+	expected := must(json.Marshal(c))
+
+	buf := &bytes.Buffer{}
+	if err := WriteSnapshot(buf, c, SnapshotRaw); err != nil {
+		t.Fatalf("failed to write snapshot: %s", err)
+	}
+	for _, from := range []*Checkpoint{c, must(ReadSnapshot(buf))} {
+		r := must(RestoreOrderBook(b.Market, from))
+		if j := must(json.Marshal(r.Checkpoint())); !bytes.Equal(j, expected) {
+			t.Errorf("restored book differs:\n%s\n%s", j, expected)
+		}
+		if !r.Halted || r.LastPrice.Cmp(b.LastPrice) != 0 {
+			t.Errorf("trading state was not restored")
+		}
+
+		// resuming and trading at 94 triggers the stop, which cancels the take profit
+		r.Resume()
+		must(r.Execute(testOrder("b3", "bob", TypeBid, "2", "94")))
+		must(r.Execute(testOrder("a4", "carol", TypeAsk, "1.1", "94")))
+		if len(r.Stops) != 0 || len(r.Asks) != 0 {
+			t.Errorf("expected stop to trigger and cancel its group, got asks=%d stops=%d", len(r.Asks), len(r.Stops))
+		}
+	}
+}
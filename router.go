@@ -0,0 +1,169 @@
+package ellipxobj
+
+import "sort"
+
+// Router finds how to convert an asset into another using a set of order books,
+// either directly on a pair of the two assets (in any direction), or through an
+// intermediate asset.
+type Router struct {
+	Books map[PairName]*OrderBook
+}
+
+// RouteLeg is one step of a Route, converting From into To on a given book
+type RouteLeg struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Order *Order `json:"order"` // Order to execute on the book of Order.Pair
+	Quote *Quote `json:"quote"` // Estimated execution of Order
+}
+
+// Route describes how to convert Amount of From into To
+type Route struct {
+	From     string      `json:"from"`
+	To       string      `json:"to"`
+	Amount   *Amount     `json:"amount"`   // Quantity of From to convert
+	Output   *Amount     `json:"output"`   // Estimated quantity of To obtained
+	Complete bool        `json:"complete"` // True if all of Amount can be converted
+	Legs     []*RouteLeg `json:"legs"`
+}
+
+// NewRouter returns a Router using the given books
+func NewRouter(books ...*OrderBook) *Router {
+	r := &Router{Books: make(map[PairName]*OrderBook)}
+	for _, b := range books {
+		r.Books[b.Market.Pair] = b
+	}
+	return r
+}
+
+// Route returns the best route to convert amount of asset from into asset to. All
+// possible routes with one leg (a book of from and to in either order) or two legs
+// (through any other asset) are estimated using OrderBook.Quote. Routes that can
+// convert the whole amount are preferred, then the route giving the largest output.
+//
+// Orders of legs have no id set. The input of the second leg is the estimated output
+// of the first, and should be adjusted to the actual output once the first leg was
+// executed.
+func (r *Router) Route(from, to string, amount *Amount) (*Route, error) {
+	var best *Route
+
+	for _, path := range r.paths(from, to) {
+		rt := r.estimate(path, from, to, amount)
+		if rt == nil {
+			continue
+		}
+		if best == nil || (rt.Complete && !best.Complete) || (rt.Complete == best.Complete && cmpAligned(rt.Output, best.Output) > 0) {
+			best = rt
+		}
+	}
+
+	if best == nil {
+		return nil, ErrNoRoute
+	}
+	return best, nil
+}
+
+// paths returns all sequences of books that go from asset from to asset to in one or
+// two steps
+func (r *Router) paths(from, to string) [][]*OrderBook {
+	var res [][]*OrderBook
+	for _, b := range r.booksFor(from, to) {
+		res = append(res, []*OrderBook{b})
+	}
+
+	// collect intermediate assets in a stable order
+	var assets []string
+	seen := map[string]bool{from: true, to: true}
+	for p := range r.Books {
+		for _, a := range p {
+			if !seen[a] {
+				seen[a] = true
+				assets = append(assets, a)
+			}
+		}
+	}
+	sort.Strings(assets)
+
+	for _, x := range assets {
+		for _, b1 := range r.booksFor(from, x) {
+			for _, b2 := range r.booksFor(x, to) {
+				res = append(res, []*OrderBook{b1, b2})
+			}
+		}
+	}
+	return res
+}
+
+// booksFor returns the books trading assets a and b, in either order
+func (r *Router) booksFor(a, b string) []*OrderBook {
+	var res []*OrderBook
+	if book, ok := r.Books[Pair(a, b)]; ok {
+		res = append(res, book)
+	}
+	if book, ok := r.Books[Pair(b, a)]; ok {
+		res = append(res, book)
+	}
+	return res
+}
+
+// estimate computes the route following the given books, or nil if nothing can be
+// converted
+func (r *Router) estimate(path []*OrderBook, from, to string, amount *Amount) *Route {
+	rt := &Route{From: from, To: to, Amount: amount, Complete: true}
+
+	asset, input := from, amount
+	for _, b := range path {
+		o := ConvertOrder(b.Market, asset, input)
+		q, err := b.Quote(o)
+		if err != nil || q.Amount.Sign() <= 0 {
+			return nil
+		}
+
+		leg := &RouteLeg{From: asset, Order: o, Quote: q}
+		if o.Type == TypeAsk {
+			// sold the base asset, received the quote asset
+			leg.To, input = b.Market.Pair[1], q.Spent
+		} else {
+			leg.To, input = b.Market.Pair[0], q.Amount
+		}
+		rt.Legs = append(rt.Legs, leg)
+		rt.Complete = rt.Complete && q.Complete
+		asset = leg.To
+	}
+
+	rt.Output = input
+	return rt
+}
+
+// ConvertOrder returns a market order converting amount of asset from into the
+// other asset of market m. If from is the base asset of the pair this is a sell
+// order of amount, otherwise this is the reverse (see Order.Reverse): a buy order
+// spending up to amount.
+func ConvertOrder(m *Market, from string, amount *Amount) *Order {
+	if from == m.Pair[0] {
+		o := NewOrder(m.Pair, TypeAsk)
+		o.Amount = amount.Dup().SetExp(m.AmountExp)
+		return o
+	}
+	o := NewOrder(Pair(m.Pair[1], m.Pair[0]), TypeAsk)
+	o.Amount = amount.Dup()
+	return o.Reverse()
+}
+
+// cmpAligned compares two amounts which may have different exponents
+func cmpAligned(a, b *Amount) int {
+	exp := max(a.exp, b.exp)
+	return a.Dup().SetExp(exp).Cmp(b.Dup().SetExp(exp))
+}
+
+// Implied returns the implied depth of market m through the asset via, built from
+// the books of the router (see ImpliedDepth). This allows showing liquidity for
+// pairs that have no book, or a thin one.
+func (r *Router) Implied(m *Market, via string, levels int) (*Depth, error) {
+	a := r.booksFor(m.Pair[0], via)
+	b := r.booksFor(m.Pair[1], via)
+	if len(a) == 0 || len(b) == 0 {
+		return nil, ErrNoRoute
+	}
+	return ImpliedDepth(m, a[0].Depth(0), b[0].Depth(0), levels)
+}
@@ -0,0 +1,50 @@
+package ellipxobj
+
+import (
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	rest := func(b *OrderBook, typ OrderType, amount, price string) {
+		o := NewOrder(b.Market.Pair, typ).SetId("r", "test")
+		o.Amount = must(NewAmountFromString(amount, b.Market.AmountExp))
+		o.Price = must(NewAmountFromString(price, b.Market.PriceExp))
+		must(b.Execute(o))
+	}
+
+	btc := NewOrderBook(testMarket())
+	eth := NewOrderBook(&Market{Pair: Pair("ETH", "USD"), AmountExp: 8, PriceExp: 5})
+	ethbtc := NewOrderBook(&Market{Pair: Pair("ETH", "BTC"), AmountExp: 8, PriceExp: 8})
+	rest(btc, TypeAsk, "10", "50000")
+	rest(eth, TypeBid, "10", "2000")
+	rest(ethbtc, TypeBid, "10", "0.05")
+	r := NewRouter(btc, eth, ethbtc)
+
+	// direct gives 0.05 BTC, through USD 2000/50000 = 0.04 BTC
+	rt := must(r.Route("ETH", "BTC", must(NewAmountFromString("1", 8))))
+	if len(rt.Legs) != 1 || rt.Output.String() != "0.05000000" || !rt.Complete {
+		t.Errorf("unexpected direct route %+v", rt)
+	}
+
+	// reversed direct route: buy ETH spending BTC, 0.01 / 0.0625 = 0.16 ETH
+	rest(ethbtc, TypeAsk, "10", "0.0625")
+	rt = must(r.Route("BTC", "ETH", must(NewAmountFromString("0.01", 8))))
+	if len(rt.Legs) != 1 || rt.Output.String() != "0.16000000" || rt.Legs[0].Order.Type != TypeBid {
+		t.Errorf("unexpected reversed route %+v", rt)
+	}
+
+	// make USD route better: 3000/50000 = 0.06 BTC
+	rest(eth, TypeBid, "10", "3000")
+	rt = must(r.Route("ETH", "BTC", must(NewAmountFromString("1", 8))))
+	if len(rt.Legs) != 2 || rt.Output.String() != "0.06000000" {
+		t.Fatalf("unexpected route %+v", rt)
+	}
+	leg := rt.Legs[1]
+	if leg.From != "USD" || leg.To != "BTC" || leg.Order.Type != TypeBid || leg.Order.SpendLimit.String() != "3000.00000" || leg.Order.Amount != nil {
+		t.Errorf("unexpected second leg %+v", leg.Order)
+	}
+
+	if _, err := r.Route("ETH", "EUR", must(NewAmountFromString("1", 8))); err != ErrNoRoute {
+		t.Errorf("expected no route, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package ellipxobj
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SelfTradeMode defines what happens when an incoming order would match a
+// resting order owned by the same user (see Order.IsSelfTrade).
+type SelfTradeMode int
+
+const (
+	SelfTradeNone         SelfTradeMode = iota // self-trades are allowed (default)
+	SelfTradeCancelNewest                      // cancel the incoming order
+	SelfTradeCancelOldest                      // cancel the resting order, continue matching
+	SelfTradeCancelBoth                        // cancel both orders
+	SelfTradeDecrement                         // decrement both by the smaller quantity, cancel any order left empty
+	SelfTradeInvalid      SelfTradeMode = -1
+)
+
+func (m SelfTradeMode) String() string {
+	switch m {
+	case SelfTradeNone:
+		return "none"
+	case SelfTradeCancelNewest:
+		return "cancel_newest"
+	case SelfTradeCancelOldest:
+		return "cancel_oldest"
+	case SelfTradeCancelBoth:
+		return "cancel_both"
+	case SelfTradeDecrement:
+		return "decrement"
+	default:
+		return "invalid"
+	}
+}
+
+func (m SelfTradeMode) IsValid() bool {
+	switch m {
+	case SelfTradeNone, SelfTradeCancelNewest, SelfTradeCancelOldest, SelfTradeCancelBoth, SelfTradeDecrement:
+		return true
+	default:
+		return false
+	}
+}
+
+func SelfTradeModeByString(s string) SelfTradeMode {
+	switch s {
+	case "none", "":
+		return SelfTradeNone
+	case "cancel_newest":
+		return SelfTradeCancelNewest
+	case "cancel_oldest":
+		return SelfTradeCancelOldest
+	case "cancel_both":
+		return SelfTradeCancelBoth
+	case "decrement":
+		return SelfTradeDecrement
+	default:
+		return SelfTradeInvalid
+	}
+}
+
+func (m SelfTradeMode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *SelfTradeMode) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	v := SelfTradeModeByString(s)
+	if v == SelfTradeInvalid {
+		return fmt.Errorf("invalid self-trade mode %q", s)
+	}
+	*m = v
+	return nil
+}
+
+// IsSelfTrade returns true if orders a and b belong to the same owner, meaning
+// a trade between them would be a self-trade. Orders are considered to share an
+// owner if they were issued by the same broker for the same UserId. Orders
+// without UserId are owned by the broker itself.
+func (a *Order) IsSelfTrade(b *Order) bool {
+	return a.BrokerId == b.BrokerId && a.UserId == b.UserId
+}
@@ -0,0 +1,170 @@
+package ellipxobj
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// Signature algorithms for SignedOrder
+const (
+	SignEd25519    = "ed25519"
+	SignHMACSHA256 = "hmac-sha256"
+)
+
+// SignedOrder is an order along with the signature of the broker that issued it,
+// computed over the canonical encoding of the order (see Order.Bytes). Orders must
+// be signed before being submitted, since any change invalidates the signature.
+type SignedOrder struct {
+	Order     *Order `json:"order"`
+	Algorithm string `json:"alg"` // SignEd25519 or SignHMACSHA256
+	KeyId     string `json:"kid"`
+	Signature []byte `json:"sig"`
+}
+
+// SignOrder signs o with an Ed25519 private key
+func SignOrder(o *Order, keyId string, key ed25519.PrivateKey) *SignedOrder {
+	return &SignedOrder{
+		Order:     o,
+		Algorithm: SignEd25519,
+		KeyId:     keyId,
+		Signature: ed25519.Sign(key, o.Bytes(nil)),
+	}
+}
+
+// SignOrderHMAC signs o with a secret shared with the exchange, using HMAC-SHA256
+func SignOrderHMAC(o *Order, keyId string, secret []byte) *SignedOrder {
+	return &SignedOrder{
+		Order:     o,
+		Algorithm: SignHMACSHA256,
+		KeyId:     keyId,
+		Signature: orderHMAC(o, secret),
+	}
+}
+
+func orderHMAC(o *Order, secret []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write(o.Bytes(nil))
+	return h.Sum(nil)
+}
+
+// BrokerKey is a key a broker can sign orders with. Public is used for Ed25519
+// keys, and Secret for HMAC keys.
+type BrokerKey struct {
+	BrokerId  string
+	Algorithm string
+	Public    ed25519.PublicKey
+	Secret    []byte
+}
+
+type brokerOrder struct {
+	broker, order string
+}
+
+// BrokerRegistry verifies orders signed by brokers. It is safe for concurrent use.
+//
+// Besides the signature, orders must have a RequestTime within Window of the
+// current time, and a broker can't use the same OrderId twice within Window. Since
+// older orders are rejected, ids only need to be remembered for that long.
+type BrokerRegistry struct {
+	Window time.Duration // Maximum difference between an order's RequestTime and now
+	Clock  Clock         // Source of the current time (system time if nil)
+
+	lk    sync.Mutex
+	keys  map[string]*BrokerKey
+	seen  map[brokerOrder]uint64 // RequestTime of recently seen orders
+	prune uint64                 // time of the next pruning of seen
+}
+
+// NewBrokerRegistry returns an empty registry accepting orders within window
+func NewBrokerRegistry(window time.Duration) *BrokerRegistry {
+	return &BrokerRegistry{
+		Window: window,
+		keys:   make(map[string]*BrokerKey),
+		seen:   make(map[brokerOrder]uint64),
+	}
+}
+
+// Add registers a broker key under the given key id. ErrKeyNotValid is returned
+// if the key does not match its algorithm: an Ed25519 public key of the wrong size
+// or an empty HMAC secret.
+func (r *BrokerRegistry) Add(keyId string, k *BrokerKey) error {
+	switch k.Algorithm {
+	case SignEd25519:
+		if len(k.Public) != ed25519.PublicKeySize {
+			return ErrKeyNotValid
+		}
+	case SignHMACSHA256:
+		if len(k.Secret) == 0 {
+			return ErrKeyNotValid
+		}
+	default:
+		return ErrKeyNotValid
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.keys[keyId] = k
+	return nil
+}
+
+// Remove revokes a broker key
+func (r *BrokerRegistry) Remove(keyId string) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	delete(r.keys, keyId)
+}
+
+// Verify checks the signature of s against the registered keys, then that the
+// order is recent and was not already received. On success the order is returned
+// and its id is remembered.
+func (r *BrokerRegistry) Verify(s *SignedOrder) (*Order, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	o := s.Order
+	if o == nil {
+		return nil, ErrSignatureNotValid
+	}
+	if s.Signature == nil {
+		return nil, ErrSignatureMissing
+	}
+	k, ok := r.keys[s.KeyId]
+	if !ok || k.BrokerId != o.BrokerId || k.Algorithm != s.Algorithm {
+		return nil, ErrKeyNotTrusted
+	}
+	switch s.Algorithm {
+	case SignEd25519:
+		ok = ed25519.Verify(k.Public, o.Bytes(nil), s.Signature)
+	case SignHMACSHA256:
+		ok = hmac.Equal(orderHMAC(o, k.Secret), s.Signature)
+	default:
+		ok = false
+	}
+	if !ok {
+		return nil, ErrSignatureNotValid
+	}
+
+	now := uint64(NewTimeIdWithClock(r.Clock).Unix)
+	window := uint64(r.Window / time.Second)
+	if o.RequestTime+window < now || o.RequestTime > now+window {
+		return nil, ErrOrderStale
+	}
+
+	if now >= r.prune {
+		for id, t := range r.seen {
+			if t+window < now {
+				delete(r.seen, id)
+			}
+		}
+		r.prune = now + window
+	}
+	id := brokerOrder{o.BrokerId, o.OrderId}
+	if _, found := r.seen[id]; found {
+		return nil, ErrOrderReplayed
+	}
+	r.seen[id] = o.RequestTime
+	return o, nil
+}
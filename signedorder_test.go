@@ -0,0 +1,59 @@
+package ellipxobj
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+)
+
+func TestSignedOrder(t *testing.T) {
+	clock := NewManualClock(time.Unix(1715773941, 0))
+	pub, key, _ := ed25519.GenerateKey(nil)
+	r := NewBrokerRegistry(time.Minute)
+	r.Clock = clock
+	if err := r.Add("k1", &BrokerKey{BrokerId: "broker1", Algorithm: SignEd25519, Public: pub}); err != nil {
+		t.Fatalf("failed to add key: %s", err)
+	}
+	if err := r.Add("k2", &BrokerKey{BrokerId: "broker2", Algorithm: SignHMACSHA256, Secret: []byte("secret")}); err != nil {
+		t.Fatalf("failed to add key: %s", err)
+	}
+	for _, k := range []*BrokerKey{
+		{BrokerId: "broker3", Algorithm: SignEd25519, Public: pub[:16]},
+		{BrokerId: "broker3", Algorithm: SignHMACSHA256},
+		{BrokerId: "broker3", Algorithm: "none", Secret: []byte("secret")},
+	} {
+		if err := r.Add("k3", k); err != ErrKeyNotValid {
+			t.Errorf("expected invalid key for %s, got %v", k.Algorithm, err)
+		}
+	}
+
+	o := NewOrderWithClock(Pair("BTC", "USD"), TypeBid, clock).SetId("o1", "broker1")
+	o.Amount = NewAmount(100000000, 8)
+	if _, err := r.Verify(SignOrder(o, "k1", key)); err != nil {
+		t.Errorf("failed to verify order: %s", err)
+	}
+	if _, err := r.Verify(SignOrder(o, "k1", key)); err != ErrOrderReplayed {
+		t.Errorf("expected replayed order, got %v", err)
+	}
+
+	h := NewOrderWithClock(Pair("BTC", "USD"), TypeAsk, clock).SetId("o1", "broker2")
+	h.Amount = NewAmount(100000000, 8)
+	s := SignOrderHMAC(h, "k2", []byte("secret"))
+	h.Amount = NewAmount(200000000, 8)
+	if _, err := r.Verify(s); err != ErrSignatureNotValid {
+		t.Errorf("expected invalid signature, got %v", err)
+	}
+	if _, err := r.Verify(SignOrderHMAC(h, "k1", []byte("secret"))); err != ErrKeyNotTrusted {
+		t.Errorf("expected untrusted key, got %v", err)
+	}
+
+	// the id can be reused once the first order is too old to be accepted
+	clock.Advance(2 * time.Minute)
+	if _, err := r.Verify(SignOrderHMAC(h, "k2", []byte("secret"))); err != ErrOrderStale {
+		t.Errorf("expected stale order, got %v", err)
+	}
+	o.RequestTime = uint64(clock.Now().Unix())
+	if _, err := r.Verify(SignOrder(o, "k1", key)); err != nil {
+		t.Errorf("failed to verify order: %s", err)
+	}
+}
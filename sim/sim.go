@@ -0,0 +1,235 @@
+// Package sim generates synthetic order flow, runs it through an ellipxobj
+// OrderBook and reports throughput, latency and book statistics. It is meant for
+// capacity planning and to detect regressions in matching performance.
+//
+// Simulations are deterministic for a given Config: order flow is generated from
+// Seed and the book uses a simulated clock. Only the measured latencies vary.
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/EllipX/ellipxobj"
+)
+
+// Config describes the order flow to generate
+type Config struct {
+	Market *ellipxobj.Market
+	Mid    float64 // Initial mid price, then the mid of the book when both sides have orders
+	Spread float64 // Standard deviation of limit prices around the mid, relative to it (0.01 = 1%)
+
+	Orders      int     // Number of operations to run (orders and cancels)
+	Rate        float64 // Average number of operations per second of simulated time
+	MarketRatio float64 // Fraction of orders that are market orders
+	CancelRatio float64 // Fraction of operations that cancel a random resting order
+
+	MinAmount float64 // Minimum order amount
+	MaxAmount float64 // Maximum order amount
+	Users     int     // Number of distinct users placing orders (100 if zero)
+	Seed      uint64  // Random seed
+	Start     time.Time
+}
+
+// Generator produces the order flow described by a Config
+type Generator struct {
+	cfg   *Config
+	rng   *rand.Rand
+	clock *ellipxobj.ManualClock
+	n     int
+}
+
+// NewGenerator returns a generator for cfg. Its clock starts at cfg.Start.
+func NewGenerator(cfg *Config) *Generator {
+	return &Generator{
+		cfg:   cfg,
+		rng:   rand.New(rand.NewPCG(cfg.Seed, cfg.Seed^0x5eed)),
+		clock: ellipxobj.NewManualClock(cfg.Start),
+	}
+}
+
+// Clock returns the simulated clock, which advances on each call to Tick
+func (g *Generator) Clock() ellipxobj.Clock {
+	return g.clock
+}
+
+// Tick advances the simulated clock to the time of the next operation, following
+// a Poisson process of rate cfg.Rate
+func (g *Generator) Tick() {
+	if g.cfg.Rate > 0 {
+		g.clock.Advance(time.Duration(g.rng.ExpFloat64() / g.cfg.Rate * float64(time.Second)))
+	}
+}
+
+// IsCancel randomly returns true for a fraction cfg.CancelRatio of operations
+func (g *Generator) IsCancel() bool {
+	return g.rng.Float64() < g.cfg.CancelRatio
+}
+
+// Pick returns a random number in [0, n)
+func (g *Generator) Pick(n int) int {
+	return g.rng.IntN(n)
+}
+
+// Order returns a new random order. Limit prices follow a normal distribution
+// around mid.
+func (g *Generator) Order(mid float64) *ellipxobj.Order {
+	m := g.cfg.Market
+	g.n += 1
+
+	typ := ellipxobj.TypeBid
+	if g.rng.IntN(2) == 1 {
+		typ = ellipxobj.TypeAsk
+	}
+	users := g.cfg.Users
+	if users <= 0 {
+		users = 100
+	}
+
+	o := ellipxobj.NewOrderWithClock(m.Pair, typ, g.clock).SetId(fmt.Sprintf("sim-%d", g.n), "sim")
+	o.UserId = fmt.Sprintf("user-%d", g.rng.IntN(users))
+
+	amount := g.cfg.MinAmount + g.rng.Float64()*(g.cfg.MaxAmount-g.cfg.MinAmount)
+	// NewAmountFromFloat64 uses at least 5 decimals
+	o.Amount, _ = ellipxobj.NewAmountFromFloat64(amount, m.AmountExp)
+	o.Amount.SetExp(m.AmountExp)
+	if o.Amount.Sign() <= 0 {
+		o.Amount = ellipxobj.NewAmount(1, m.AmountExp)
+	}
+
+	if g.rng.Float64() >= g.cfg.MarketRatio {
+		price := mid * (1 + g.rng.NormFloat64()*g.cfg.Spread)
+		o.Price, _ = ellipxobj.NewAmountFromFloat64(math.Max(price, mid/100), m.PriceExp)
+		o.Price.SetExp(m.PriceExp)
+		if o.Price.Sign() <= 0 {
+			o.Price = ellipxobj.NewAmount(1, m.PriceExp)
+		}
+	}
+	return o
+}
+
+// Latency holds percentiles of the time taken by book operations
+type Latency struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// Report holds the results of a simulation
+type Report struct {
+	Orders   int `json:"orders"`   // Orders executed
+	Cancels  int `json:"cancels"`  // Orders cancelled
+	Rejected int `json:"rejected"` // Orders rejected by the book
+	Trades   int `json:"trades"`
+	Events   int `json:"events"`
+
+	Volume    *ellipxobj.Amount `json:"volume"`      // Quantity of base asset traded
+	SimTime   time.Duration     `json:"sim_time"`    // Simulated duration
+	Elapsed   time.Duration     `json:"elapsed"`     // Time spent in book operations
+	OpsPerSec float64           `json:"ops_per_sec"` // Operations per second of Elapsed
+	Latency   Latency           `json:"latency"`
+
+	BidOrders int               `json:"bid_orders"` // Final resting bids
+	AskOrders int               `json:"ask_orders"` // Final resting asks
+	BidLevels int               `json:"bid_levels"`
+	AskLevels int               `json:"ask_levels"`
+	Spread    *ellipxobj.Amount `json:"spread,omitempty"` // Final spread, if both sides have orders
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("%d orders, %d cancels, %d rejected, %d trades (volume %s) in %s simulated; %.0f ops/s, latency p50=%s p90=%s p99=%s max=%s; book %d/%d orders, %d/%d levels, spread %v",
+		r.Orders, r.Cancels, r.Rejected, r.Trades, r.Volume, r.SimTime, r.OpsPerSec,
+		r.Latency.P50, r.Latency.P90, r.Latency.P99, r.Latency.Max,
+		r.BidOrders, r.AskOrders, r.BidLevels, r.AskLevels, r.Spread)
+}
+
+// Run simulates the order flow of cfg on a new book and returns the report and the
+// final state of the book
+func Run(cfg *Config) (*Report, *ellipxobj.OrderBook) {
+	g := NewGenerator(cfg)
+	b := ellipxobj.NewOrderBook(cfg.Market)
+	b.Clock = g.Clock()
+
+	r := &Report{Volume: ellipxobj.NewAmount(0, cfg.Market.AmountExp)}
+	lat := make([]time.Duration, 0, cfg.Orders)
+
+	for range cfg.Orders {
+		g.Tick()
+
+		var events []*ellipxobj.Event
+		var err error
+		resting := len(b.Bids) + len(b.Asks)
+		if resting > 0 && g.IsCancel() {
+			o := b.Bids
+			n := g.Pick(resting)
+			if n >= len(b.Bids) {
+				o, n = b.Asks, n-len(b.Bids)
+			}
+			start := time.Now()
+			events, err = b.Cancel(*o[n].Unique)
+			lat = append(lat, time.Since(start))
+			r.Cancels += 1
+		} else {
+			o := g.Order(mid(b, cfg.Mid))
+			start := time.Now()
+			events, err = b.Execute(o)
+			lat = append(lat, time.Since(start))
+			r.Orders += 1
+		}
+		if err != nil {
+			r.Rejected += 1
+			continue
+		}
+
+		r.Events += len(events)
+		for _, ev := range events {
+			if ev.Type == ellipxobj.EventTrade {
+				r.Trades += 1
+				r.Volume = r.Volume.Add(r.Volume, ev.Trade.Amount)
+			}
+		}
+	}
+
+	r.SimTime = g.Clock().Now().Sub(cfg.Start)
+	for _, d := range lat {
+		r.Elapsed += d
+	}
+	if r.Elapsed > 0 {
+		r.OpsPerSec = float64(len(lat)) / r.Elapsed.Seconds()
+	}
+	r.Latency = percentiles(lat)
+
+	d := b.Depth(0)
+	r.BidOrders, r.AskOrders = len(b.Bids), len(b.Asks)
+	r.BidLevels, r.AskLevels = len(d.Bids), len(d.Asks)
+	if len(b.Bids) > 0 && len(b.Asks) > 0 {
+		r.Spread = ellipxobj.NewAmount(0, cfg.Market.PriceExp).Sub(b.Asks[0].Price, b.Bids[0].Price)
+	}
+	return r, b
+}
+
+// mid returns the mid price of the book, or def if a side is empty
+func mid(b *ellipxobj.OrderBook, def float64) float64 {
+	if len(b.Bids) == 0 || len(b.Asks) == 0 {
+		return def
+	}
+	bid, _ := b.Bids[0].Price.Float().Float64()
+	ask, _ := b.Asks[0].Price.Float().Float64()
+	return (bid + ask) / 2
+}
+
+func percentiles(lat []time.Duration) Latency {
+	if len(lat) == 0 {
+		return Latency{}
+	}
+	s := slices.Clone(lat)
+	slices.Sort(s)
+	at := func(p float64) time.Duration {
+		return s[int(p*float64(len(s)-1))]
+	}
+	return Latency{P50: at(0.5), P90: at(0.9), P99: at(0.99), Max: s[len(s)-1]}
+}
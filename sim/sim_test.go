@@ -0,0 +1,49 @@
+package sim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EllipX/ellipxobj"
+)
+
+func testConfig(orders int) *Config {
+	return &Config{
+		Market:      &ellipxobj.Market{Pair: ellipxobj.Pair("BTC", "USD"), AmountExp: 8, PriceExp: 2},
+		Mid:         50000,
+		Spread:      0.002,
+		Orders:      orders,
+		Rate:        1000,
+		MarketRatio: 0.1,
+		CancelRatio: 0.3,
+		MinAmount:   0.001,
+		MaxAmount:   2,
+		Seed:        42,
+		Start:       time.Unix(1700000000, 0),
+	}
+}
+
+func TestRun(t *testing.T) {
+	r1, b1 := Run(testConfig(5000))
+	r2, b2 := Run(testConfig(5000))
+
+	if r1.Orders+r1.Cancels != 5000 || r1.Trades == 0 || r1.Cancels == 0 || r1.Rejected != 0 {
+		t.Errorf("unexpected report %s", r1)
+	}
+	if r1.Trades != r2.Trades || r1.Volume.String() != r2.Volume.String() || r1.SimTime != r2.SimTime {
+		t.Errorf("simulation is not deterministic:\n%s\n%s", r1, r2)
+	}
+	if string(b1.Checkpoint().OrderSum) != string(b2.Checkpoint().OrderSum) {
+		t.Errorf("simulation is not deterministic, books differ")
+	}
+	if r1.Spread == nil || r1.Spread.Sign() <= 0 {
+		t.Errorf("book must not be crossed, spread %v", r1.Spread)
+	}
+	t.Log(r1)
+}
+
+func BenchmarkRun(b *testing.B) {
+	cfg := testConfig(b.N)
+	b.ResetTimer()
+	Run(cfg)
+}
@@ -0,0 +1,99 @@
+package ellipxobj
+
+// Fill is the result of simulating the execution of an order against a book
+type Fill struct {
+	Trades   []*Trade `json:"trades"`   // Trades that would happen (without Id)
+	Amount   *Amount  `json:"amount"`   // Total quantity of base asset that would be traded
+	Spent    *Amount  `json:"spent"`    // Total quantity of quote asset that would be traded
+	Complete bool     `json:"complete"` // True if the order would be fully executed
+}
+
+// Simulate computes the trades that would happen if order o was executed now,
+// without modifying the book, o or any of the resting orders. Quantities are
+// computed the same way as actual matching (see Order.TradeAmount), and the
+// market's collar, price bands and self-trade prevention are taken into account.
+// The hidden quantity of iceberg orders is included.
+//
+// This is used to reject fill-or-kill orders that can't be fully executed, and can
+// be used to estimate the result of an order before submitting it. The amounts of o
+// must fit the market's precision, or ErrAmountPrecision is returned.
+func (b *OrderBook) Simulate(o *Order) (*Fill, error) {
+	o = o.Dup()
+	if err := b.Market.normalize(o); err != nil {
+		return nil, err
+	}
+	return b.simulate(o), nil
+}
+
+// simulate is Simulate for an order already normalized
+func (b *OrderBook) simulate(o *Order) *Fill {
+	res := &Fill{
+		Amount: NewAmount(0, b.Market.AmountExp),
+		Spent:  NewAmount(0, b.Market.PriceExp),
+	}
+
+	// work on a copy of the quantities of o
+	taker := &Order{}
+	*taker = *o
+	taker.Amount = o.Amount.Dup()
+	taker.SpendLimit = o.SpendLimit.Dup()
+	taker.Visible = nil
+
+	side := *b.side(o.Type.Reverse())
+	var limit, last *Amount
+	if o.Price == nil && len(side) > 0 {
+		limit = b.Market.collar(o.Type, side[0].Price)
+	}
+
+	for _, m := range side {
+		if !b.matching() || isExhausted(taker) {
+			break
+		}
+		if limit != nil && !priceWithin(o.Type, m.Price, limit) {
+			break
+		}
+		if !taker.crosses(m) || b.checkBands(m.Price) != nil {
+			break
+		}
+		t := taker.Matches(m)
+		if t == nil {
+			break
+		}
+		if mode := b.selfTradeMode(o, m); mode != SelfTradeNone {
+			if mode == SelfTradeCancelOldest {
+				// m would be cancelled, and matching continues
+				continue
+			}
+			if mode == SelfTradeDecrement {
+				// both orders would be reduced without trading
+				taker.Deduct(t)
+				if !isExhausted(taker) {
+					continue
+				}
+			}
+			// o would be cancelled
+			return res
+		}
+
+		res.Trades = append(res.Trades, t)
+		res.Amount = res.Amount.Add(res.Amount, t.Amount)
+		spent := t.Spent()
+		res.Spent = res.Spent.Add(res.Spent, spent)
+		last = m.Price
+
+		if taker.Amount != nil {
+			taker.Amount = taker.Amount.Sub(taker.Amount, t.Amount)
+		}
+		if taker.SpendLimit != nil {
+			taker.SpendLimit = taker.SpendLimit.Sub(taker.SpendLimit, spent)
+		}
+	}
+
+	res.Complete = isExhausted(taker)
+	if !res.Complete && last != nil {
+		// a SpendLimit can leave a quantity too small to buy anything
+		unit := &Order{Price: last, Amount: NewAmount(1, b.Market.AmountExp)}
+		res.Complete = taker.TradeAmount(unit).Sign() <= 0
+	}
+	return res
+}
@@ -0,0 +1,304 @@
+package ellipxobj
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// Snapshot files start with snapshotMagic, a version and a compression byte,
+// followed by the (possibly compressed) body. The body holds the length prefixed
+// checkpoint header (including order groups), then the orders. Each order is
+// prefixed with its length, and a zero length ends the bids, then the asks, then
+// the stop orders.
+const (
+	snapshotMagic     = "ELXS"
+	snapshotVersion   = 0x01
+	snapshotMaxRecord = 16 << 20
+	snapshotSides     = 3 // bids, asks and stops
+)
+
+// SnapshotCompression is the compression used for the body of a snapshot
+type SnapshotCompression byte
+
+const (
+	SnapshotRaw  SnapshotCompression = iota // no compression
+	SnapshotGzip                            // gzip compression
+)
+
+// SnapshotWriter writes a Checkpoint in the binary snapshot format one order at a
+// time, so large checkpoints don't need to be held in memory.
+type SnapshotWriter struct {
+	w    *bufio.Writer
+	gz   *gzip.Writer
+	side int // current side: bids, asks or stops
+	buf  []byte
+}
+
+// NewSnapshotWriter writes the header of a snapshot of c to w, excluding its
+// orders, which are then written with WriteOrder. Close must be called once all
+// orders have been written.
+func NewSnapshotWriter(w io.Writer, c *Checkpoint, compression SnapshotCompression) (*SnapshotWriter, error) {
+	if _, err := w.Write(append([]byte(snapshotMagic), snapshotVersion, byte(compression))); err != nil {
+		return nil, err
+	}
+
+	s := &SnapshotWriter{}
+	switch compression {
+	case SnapshotRaw:
+		s.w = bufio.NewWriter(w)
+	case SnapshotGzip:
+		s.gz = gzip.NewWriter(w)
+		s.w = bufio.NewWriter(s.gz)
+	default:
+		return nil, ErrSnapshotFormat
+	}
+
+	buf := appendString(nil, c.Pair[0])
+	buf = appendString(buf, c.Pair[1])
+	buf = binary.AppendUvarint(buf, c.Epoch)
+	buf = binary.AppendUvarint(buf, c.PrevEpoch)
+	buf = appendBytes(buf, c.PrevHash)
+	buf = appendTimeId(buf, &c.Point)
+	buf = appendBytes(buf, c.OrderSum)
+	buf = binary.AppendUvarint(buf, c.OrderCount)
+	buf = c.appendState(buf)
+	buf = appendString(buf, c.KeyId)
+	buf = appendBytes(buf, c.Signature)
+	if _, err := s.w.Write(appendBytes(nil, buf)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// WriteOrder appends an order to the snapshot. All bids must be written before
+// asks, then stop orders (orders with status OrderStop), in the order of the
+// checkpoint.
+func (s *SnapshotWriter) WriteOrder(o *Order) error {
+	side := 0
+	switch {
+	case o.Status == OrderStop:
+		side = 2
+	case o.Type == TypeAsk:
+		side = 1
+	}
+	return s.write(side, o)
+}
+
+// write appends order o to the given side of the snapshot
+func (s *SnapshotWriter) write(side int, o *Order) error {
+	if side < s.side {
+		return ErrSnapshotFormat
+	}
+	for s.side < side {
+		if err := s.endSide(); err != nil {
+			return err
+		}
+	}
+
+	s.buf = o.Bytes(s.buf[:0])
+	if _, err := s.w.Write(binary.AppendUvarint(nil, uint64(len(s.buf)))); err != nil {
+		return err
+	}
+	_, err := s.w.Write(s.buf)
+	return err
+}
+
+// endSide ends the current side of the book
+func (s *SnapshotWriter) endSide() error {
+	s.side += 1
+	return s.w.WriteByte(0)
+}
+
+// Close ends the snapshot and flushes any buffered data. The underlying writer is
+// not closed.
+func (s *SnapshotWriter) Close() error {
+	for s.side < snapshotSides {
+		if err := s.endSide(); err != nil {
+			return err
+		}
+	}
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	if s.gz != nil {
+		return s.gz.Close()
+	}
+	return nil
+}
+
+// WriteSnapshot writes checkpoint c to w in the binary snapshot format
+func WriteSnapshot(w io.Writer, c *Checkpoint, compression SnapshotCompression) error {
+	s, err := NewSnapshotWriter(w, c, compression)
+	if err != nil {
+		return err
+	}
+	for n, side := range c.orders() {
+		for _, o := range side {
+			if err := s.write(n, o); err != nil {
+				return err
+			}
+		}
+	}
+	return s.Close()
+}
+
+// SnapshotReader reads a snapshot written by SnapshotWriter one order at a time
+type SnapshotReader struct {
+	Checkpoint *Checkpoint // Checkpoint header, without orders
+
+	r    *bufio.Reader
+	side int // number of sides fully read
+}
+
+// NewSnapshotReader reads the header of a snapshot from r. Orders are then read
+// with Next.
+func NewSnapshotReader(r io.Reader) (*SnapshotReader, error) {
+	hdr := make([]byte, len(snapshotMagic)+2)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	if string(hdr[:len(snapshotMagic)]) != snapshotMagic || hdr[len(snapshotMagic)] != snapshotVersion {
+		return nil, ErrSnapshotFormat
+	}
+
+	s := &SnapshotReader{}
+	switch SnapshotCompression(hdr[len(snapshotMagic)+1]) {
+	case SnapshotRaw:
+		s.r = bufio.NewReader(r)
+	case SnapshotGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		s.r = bufio.NewReader(gz)
+	default:
+		return nil, ErrSnapshotFormat
+	}
+
+	buf, err := s.record()
+	if err != nil {
+		return nil, err
+	}
+	h := &binReader{data: buf}
+	c := &Checkpoint{
+		Pair:      PairName{h.string(), h.string()},
+		Epoch:     h.uvarint(),
+		PrevEpoch: h.uvarint(),
+		PrevHash:  h.blob(),
+	}
+	if p := h.timeId(); p != nil {
+		c.Point = *p
+	}
+	c.OrderSum = h.blob()
+	c.OrderCount = h.uvarint()
+	c.LastPrice = h.amount()
+	flags := h.byte()
+	c.Halted, c.Auction = flags&1 != 0, flags&2 != 0
+	for n := h.uvarint(); n > 0 && h.err == nil; n-- {
+		c.Groups = append(c.Groups, h.group())
+	}
+	c.KeyId = h.string()
+	c.Signature = h.blob()
+	if h.err != nil || len(h.data) != 0 {
+		return nil, ErrSnapshotFormat
+	}
+	s.Checkpoint = c
+	return s, nil
+}
+
+// record reads a length prefixed record, returning nil for an empty record
+func (s *SnapshotReader) record() ([]byte, error) {
+	ln, err := binary.ReadUvarint(s.r)
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	if ln == 0 {
+		return nil, nil
+	}
+	if ln > snapshotMaxRecord {
+		return nil, ErrSnapshotFormat
+	}
+	buf := make([]byte, ln)
+	if _, err := io.ReadFull(s.r, buf); err != nil {
+		return nil, noEOF(err)
+	}
+	return buf, nil
+}
+
+// Next returns the next order of the snapshot, bids first then asks and stops, or
+// io.EOF once all orders have been read.
+func (s *SnapshotReader) Next() (*Order, error) {
+	for s.side < snapshotSides {
+		buf, err := s.record()
+		if err != nil {
+			return nil, err
+		}
+		if buf == nil {
+			s.side += 1
+			continue
+		}
+		o := &Order{}
+		if err := o.UnmarshalBinary(buf); err != nil {
+			return nil, ErrSnapshotFormat
+		}
+		return o, nil
+	}
+	return nil, io.EOF
+}
+
+// ReadSnapshot reads a whole snapshot from r
+func ReadSnapshot(r io.Reader) (*Checkpoint, error) {
+	s, err := NewSnapshotReader(r)
+	if err != nil {
+		return nil, err
+	}
+	c := s.Checkpoint
+	c.Bids, c.Asks = []*Order{}, []*Order{}
+	for {
+		o, err := s.Next()
+		switch {
+		case err == io.EOF:
+			return c, nil
+		case err != nil:
+			return nil, err
+		case s.side == 0:
+			c.Bids = append(c.Bids, o)
+		case s.side == 1:
+			c.Asks = append(c.Asks, o)
+		default:
+			c.Stops = append(c.Stops, o)
+		}
+	}
+}
+
+// noEOF converts io.EOF to io.ErrUnexpectedEOF, for data that must be present
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReadCheckpointFile reads a checkpoint from a file holding either a snapshot or
+// the JSON encoding of a Checkpoint.
+func ReadCheckpointFile(path string) (*Checkpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	if magic, _ := r.Peek(len(snapshotMagic)); string(magic) == snapshotMagic {
+		return ReadSnapshot(r)
+	}
+	c := &Checkpoint{}
+	if err := json.NewDecoder(r).Decode(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,54 @@
+package ellipxobj
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	b := NewOrderBook(testMarket())
+	must(b.Execute(testOrder("b1", "bob", TypeBid, "1", "99")))
+	ice := testOrder("b2", "bob", TypeBid, "5", "98")
+	ice.Display = must(NewAmountFromString("1", 8))
+	must(b.Execute(ice))
+	gtd := testOrder("a1", "alice", TypeAsk, "0.5", "101")
+	gtd.TimeInForce = TimeInForceGTD
+	gtd.Expires = &TimeId{Type: "expire", Unix: 4102444800}
+	must(b.Execute(gtd))
+	c := b.Checkpoint()
+	c.Epoch, c.PrevHash = 2, []byte{1, 2, 3}
+
+	expected := must(json.Marshal(c))
+	for _, comp := range []SnapshotCompression{SnapshotRaw, SnapshotGzip} {
+		buf := &bytes.Buffer{}
+		if err := WriteSnapshot(buf, c, comp); err != nil {
+			t.Fatalf("failed to write snapshot: %s", err)
+		}
+		res := must(ReadSnapshot(buf))
+		if j := must(json.Marshal(res)); !bytes.Equal(j, expected) {
+			t.Errorf("snapshot round trip failed:\n%s\n%s", j, expected)
+		}
+		if !bytes.Equal(res.Sum(), c.OrderSum) {
+			t.Errorf("snapshot orders do not match order sum")
+		}
+	}
+
+	// asks only, bids written after asks are rejected
+	buf := &bytes.Buffer{}
+	w := must(NewSnapshotWriter(buf, &Checkpoint{Pair: c.Pair}, SnapshotRaw))
+	if err := w.WriteOrder(gtd); err != nil {
+		t.Fatalf("failed to write order: %s", err)
+	}
+	if err := w.WriteOrder(ice); err != ErrSnapshotFormat {
+		t.Errorf("expected snapshot format error, got %v", err)
+	}
+	w.Close()
+	if res := must(ReadSnapshot(buf)); len(res.Bids) != 0 || len(res.Asks) != 1 {
+		t.Errorf("unexpected snapshot %+v", res)
+	}
+
+	if _, err := ReadSnapshot(bytes.NewReader(expected)); err != ErrSnapshotFormat {
+		t.Errorf("expected snapshot format error, got %v", err)
+	}
+}
@@ -29,7 +29,8 @@ type TimeId struct {
 // and monotonically increasing within a process, even when created
 // in rapid succession or with system clock changes.
 type TimeIdUnique struct {
-	Last TimeId // Tracks the last generated TimeId to ensure uniqueness
+	Last  TimeId // Tracks the last generated TimeId to ensure uniqueness
+	Clock Clock  // Source of time for New (system time if nil)
 }
 
 // Global instance for generating process-wide unique TimeIds
@@ -40,7 +41,16 @@ var uniqueTime TimeIdUnique
 // Note: This method does not guarantee uniqueness if called in rapid succession.
 // Use NewUniqueTimeId for guaranteed uniqueness.
 func NewTimeId() *TimeId {
-	t := time.Now()
+	return NewTimeIdWithClock(nil)
+}
+
+// NewTimeIdWithClock returns a new TimeId initialized with the time of clock c, or
+// the system time if c is nil.
+func NewTimeIdWithClock(c Clock) *TimeId {
+	if c == nil {
+		c = SystemClock{}
+	}
+	t := c.Now()
 	res := &TimeId{
 		Unix: uint64(t.Unix()),
 		Nano: uint32(t.Nanosecond()),
@@ -217,9 +227,10 @@ func (u *TimeIdUnique) Unique(t *TimeId) {
 
 // New creates and returns a new TimeId that is guaranteed to be unique
 // within the scope of this TimeIdUnique instance.
-// This is a convenience method that combines NewTimeId() and Unique() in one call.
+// This is a convenience method that combines NewTimeIdWithClock() and Unique() in
+// one call, using the Clock of this instance.
 func (u *TimeIdUnique) New() *TimeId {
-	t := NewTimeId()
+	t := NewTimeIdWithClock(u.Clock)
 	u.Unique(t)
 	return t
 }
@@ -0,0 +1,74 @@
+package ellipxobj
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// TimeInForce defines how long an order stays in the book before it expires
+type TimeInForce int
+
+const (
+	TimeInForceGTC     TimeInForce = iota // good till cancelled (default)
+	TimeInForceGTD                        // good till date, the order expires at Order.Expires
+	TimeInForceDay                        // day order, expires at the end of the market's session
+	TimeInForceInvalid TimeInForce = -1
+)
+
+func (t TimeInForce) String() string {
+	switch t {
+	case TimeInForceGTC:
+		return "gtc"
+	case TimeInForceGTD:
+		return "gtd"
+	case TimeInForceDay:
+		return "day"
+	default:
+		return "invalid"
+	}
+}
+
+func (t TimeInForce) IsValid() bool {
+	switch t {
+	case TimeInForceGTC, TimeInForceGTD, TimeInForceDay:
+		return true
+	default:
+		return false
+	}
+}
+
+func TimeInForceByString(s string) TimeInForce {
+	switch s {
+	case "gtc", "":
+		return TimeInForceGTC
+	case "gtd":
+		return TimeInForceGTD
+	case "day":
+		return TimeInForceDay
+	default:
+		return TimeInForceInvalid
+	}
+}
+
+func (t TimeInForce) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *TimeInForce) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	v := TimeInForceByString(s)
+	if v == TimeInForceInvalid {
+		return fmt.Errorf("invalid time in force %q", s)
+	}
+	*t = v
+	return nil
+}
+
+// IsExpired returns true if the order has an expiry time and it is not after now
+func (o *Order) IsExpired(now TimeId) bool {
+	return o.Expires != nil && o.Expires.Cmp(now) <= 0
+}
@@ -38,7 +38,9 @@ func (t *Trade) MarshalJSON() ([]byte, error) {
 		Type:   t.Type,
 		Amount: t.Amount,
 		Price:  t.Price,
-		Date:   t.Id.Time(),
+	}
+	if t.Id != nil {
+		obj.Date = t.Id.Time()
 	}
 
 	return json.Marshal(obj)
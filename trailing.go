@@ -0,0 +1,60 @@
+package ellipxobj
+
+// IsTrailing returns true if this order is a trailing stop order, meaning its
+// StopPrice follows the market at a distance of TrailOffset or TrailPercent.
+func (o *Order) IsTrailing() bool {
+	return o.Flags.Has(FlagStop) && (o.TrailOffset != nil || o.TrailPercent != nil)
+}
+
+// TriggerPrice returns the price at which this stop order will be triggered, which
+// for trailing stop orders changes as the market moves. Returns nil if the order is
+// not a stop order or if the trigger price isn't known yet.
+func (o *Order) TriggerPrice() *Amount {
+	if !o.Flags.Has(FlagStop) {
+		return nil
+	}
+	return o.StopPrice
+}
+
+// Trail updates a trailing stop order after a trade happened at the given price.
+// TrailRef keeps track of the best price since the order was placed (the highest
+// for sell orders, the lowest for buy orders), and StopPrice is moved to follow it
+// at the configured offset. The stop price only ever moves in the favourable
+// direction: up for sell orders, down for buy orders.
+//
+// Returns true if StopPrice was updated.
+func (o *Order) Trail(price *Amount) bool {
+	if !o.IsTrailing() || price == nil {
+		return false
+	}
+
+	if o.TrailRef != nil {
+		c := price.Cmp(o.TrailRef)
+		if (o.Type == TypeAsk && c <= 0) || (o.Type == TypeBid && c >= 0) {
+			// not a better price
+			return false
+		}
+	}
+	o.TrailRef = price.Dup()
+
+	offset := o.TrailOffset
+	if offset == nil {
+		offset = percentOf(price, o.TrailPercent)
+	}
+
+	stop := NewAmount(0, price.exp)
+	if o.Type == TypeAsk {
+		stop = stop.Sub(price, offset)
+	} else {
+		stop = stop.Add(price, offset)
+	}
+
+	if o.StopPrice != nil {
+		c := stop.Cmp(o.StopPrice)
+		if (o.Type == TypeAsk && c <= 0) || (o.Type == TypeBid && c >= 0) {
+			return false
+		}
+	}
+	o.StopPrice = stop
+	return true
+}